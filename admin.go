@@ -0,0 +1,127 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// runtimeMu protects values of flags changed at run time.
+var runtimeMu sync.RWMutex
+
+// MarkRuntimeMutable makes the named flags changeable at run time through
+// the handler returned by AdminHandler. Programs should read such flags
+// with getters, such as GetString, which are synchronized with changes,
+// or get notified of changes with OnChange.
+func MarkRuntimeMutable(names ...string) {
+	defaultSet.MarkRuntimeMutable(names...)
+}
+
+// MarkRuntimeMutable makes the named flags changeable at run time.
+// See the package-level MarkRuntimeMutable.
+func (fs *FlagSet) MarkRuntimeMutable(names ...string) {
+	for _, name := range names {
+		if fs.FlagSet.Lookup(name) == nil {
+			panic(fmt.Sprintf("conflag: marking undefined flag %s as runtime-mutable", name))
+		}
+		if fs.mutable == nil {
+			fs.mutable = make(map[string]bool)
+		}
+		fs.mutable[name] = true
+	}
+}
+
+// OnChange registers the function to be called after the value of a flag
+// is changed at run time, by the handler returned by AdminHandler or by
+// Reload, with the name of the flag and its old and new values.
+func OnChange(fn func(name, oldValue, newValue string)) {
+	defaultSet.OnChange(fn)
+}
+
+// OnChange registers the function to be called after the value of a flag
+// is changed at run time. See the package-level OnChange.
+func (fs *FlagSet) OnChange(fn func(name, oldValue, newValue string)) {
+	fs.onChange = append(fs.onChange, fn)
+}
+
+// AdminHandler returns an HTTP handler of the default set for changing
+// flags at run time. See FlagSet.AdminHandler.
+func AdminHandler() http.Handler {
+	return defaultSet.AdminHandler()
+}
+
+// AdminHandler returns an HTTP handler for changing flags marked with
+// MarkRuntimeMutable at run time, for example, to change the log level of
+// a running server. A GET request returns the runtime-mutable flags in
+// the JSON format written by WriteJSON. A PUT request with the form
+// parameters "name" and "value" sets the named flag and calls functions
+// registered with OnChange:
+//
+//	curl -X PUT -d name=log-level -d value=debug http://localhost:6060/debug/flags
+//
+// Setting flags that aren't runtime-mutable is forbidden. The handler must
+// be exposed only to trusted clients.
+func (fs *FlagSet) AdminHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPut:
+			name, value := r.FormValue("name"), r.FormValue("value")
+			f := fs.Lookup(name)
+			if f == nil {
+				http.Error(w, fmt.Sprintf("flag -%s is not defined", name), http.StatusNotFound)
+				return
+			}
+			if !fs.mutable[f.Name] {
+				http.Error(w, fmt.Sprintf("flag -%s can't be changed at run time", f.Name), http.StatusForbidden)
+				return
+			}
+			if err := fs.setRuntime(f.Name, value); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var flags []jsonFlag
+		for _, j := range fs.jsonFlags() {
+			if fs.mutable[j.Name] {
+				flags = append(flags, j)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(flags)
+	})
+}
+
+// setRuntime sets the named flag at run time and calls the functions
+// registered with OnChange if the value changed.
+func (fs *FlagSet) setRuntime(name, value string) error {
+	runtimeMu.Lock()
+	f := fs.FlagSet.Lookup(name)
+	old := f.Value.String()
+	err := fs.setFlag(f, name, value, SourceRuntime)
+	if err != nil && fs.IsSensitive(name) {
+		err = fmt.Errorf("invalid value for flag -%s: %v", name, err)
+	} else if err != nil {
+		err = fmt.Errorf("invalid value %q for flag -%s: %v", value, name, err)
+	}
+	v := f.Value.String()
+	runtimeMu.Unlock()
+	if err != nil {
+		return err
+	}
+	if v != old {
+		for _, fn := range fs.onChange {
+			fn(name, old, v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Alias defines alias as an alternative name for the named flag, which
+// must already be defined. The flag can then be set using either name on
+// the command line and in configuration files. The canonical name is used
+// in usage and configuration output.
+func Alias(name, alias string) {
+	defaultSet.Alias(name, alias)
+}
+
+// Alias defines alias as an alternative name for the named flag.
+// See the package-level Alias.
+func (fs *FlagSet) Alias(name, alias string) {
+	if fs.FlagSet.Lookup(name) == nil {
+		panic(fmt.Sprintf("conflag: alias %s for undefined flag %s", alias, name))
+	}
+	if fs.FlagSet.Lookup(alias) != nil || fs.aliases[alias] != "" {
+		panic(fmt.Sprintf("conflag: alias %s redefined", alias))
+	}
+	fs.aliases[alias] = name
+}
+
+// canonicalName returns the canonical flag name for the given name,
+// which may be an alias.
+func (fs *FlagSet) canonicalName(name string) string {
+	if c, ok := fs.aliases[name]; ok {
+		return c
+	}
+	return name
+}
+
+// aliasesOf returns sorted non-deprecated aliases of the named flag.
+func (fs *FlagSet) aliasesOf(name string) (a []string) {
+	for alias, c := range fs.aliases {
+		if _, ok := fs.deprecated[alias]; c == name && !ok {
+			a = append(a, alias)
+		}
+	}
+	sort.Strings(a)
+	return
+}
@@ -0,0 +1,50 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import "fmt"
+
+// Annotate attaches the key and value to the named flag as metadata, such
+// as a category, stability level, or completion hint, for use by tools
+// built on this package, such as completion and documentation generators.
+// The package itself doesn't interpret annotations. Annotating the flag
+// again with the same key replaces the value.
+func Annotate(name, key, value string) {
+	defaultSet.Annotate(name, key, value)
+}
+
+// Annotate attaches the key and value to the named flag as metadata.
+// See the package-level Annotate.
+func (fs *FlagSet) Annotate(name, key, value string) {
+	if fs.FlagSet.Lookup(name) == nil {
+		panic(fmt.Sprintf("conflag: annotating undefined flag %s", name))
+	}
+	if fs.annotations == nil {
+		fs.annotations = make(map[string]map[string]string)
+	}
+	if fs.annotations[name] == nil {
+		fs.annotations[name] = make(map[string]string)
+	}
+	fs.annotations[name][key] = value
+}
+
+// Annotations returns a copy of the annotations of the named flag,
+// or nil if it has none.
+func Annotations(name string) map[string]string {
+	return defaultSet.Annotations(name)
+}
+
+// Annotations returns a copy of the annotations of the named flag.
+func (fs *FlagSet) Annotations(name string) map[string]string {
+	a := fs.annotations[fs.canonicalName(name)]
+	if a == nil {
+		return nil
+	}
+	m := make(map[string]string, len(a))
+	for k, v := range a {
+		m[k] = v
+	}
+	return m
+}
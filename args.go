@@ -0,0 +1,59 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import "strings"
+
+// EnableConfigArgs makes Parse treat entries with the given key, such as
+// "args", in configuration files as positional arguments, which are
+// placed before the non-flag command-line arguments returned by Args.
+// This is useful for programs whose primary input is a list of paths or
+// targets. Each entry contributes one argument, so the key may be
+// repeated or, in formats that support them, given an array of values:
+//
+//	args = /var/log/syslog
+//	args = /var/log/auth.log
+//
+// Arguments from a configuration file or section replace those from files
+// and sections loaded before it. Arguments for a command are read from
+// its command section. An empty key disables the feature.
+func EnableConfigArgs(key string) {
+	defaultSet.EnableConfigArgs(key)
+}
+
+// EnableConfigArgs makes Parse treat entries with the given key in
+// configuration files as positional arguments. See the package-level
+// EnableConfigArgs.
+func (fs *FlagSet) EnableConfigArgs(key string) {
+	fs.root().argsKey = key
+}
+
+// isArgsEntry reports whether the entry contains a positional argument.
+func (fs *FlagSet) isArgsEntry(e entry) bool {
+	key := fs.root().argsKey
+	return key != "" && e.name == key
+}
+
+// addConfigArg records the positional argument from the entry, discarding
+// arguments from other files or sections.
+func (fs *FlagSet) addConfigArg(e entry) error {
+	if fs.parent != nil && !strings.HasPrefix(e.section, "command ") {
+		// Arguments for the parent set.
+		return nil
+	}
+	if !e.hasValue {
+		return e.errorf("missing value for %s", e.name)
+	}
+	if from := e.file + "\x00" + e.section; from != fs.configArgsFrom {
+		fs.configArgs, fs.configArgsFrom = nil, from
+	}
+	value := e.value
+	if fs.root().expandEnv && !e.literal {
+		value = expandValue(value)
+	}
+	fs.tracef("%s: add argument %q", e.location(), value)
+	fs.configArgs = append(fs.configArgs, value)
+	return nil
+}
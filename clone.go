@@ -0,0 +1,109 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"flag"
+	"maps"
+	"reflect"
+	"slices"
+)
+
+// Clone returns an independent copy of the default set and its commands
+// with the same flag definitions, default values, settings, such as program
+// name and configuration file paths, and constraints, so that parses, for
+// example, for each request or tenant, can start from a shared template
+// without defining flags again. Flags of the copy have new values set to
+// the defaults, which are accessed with Lookup or getters, such as
+// GetString. Values of types that can't be created from the default are
+// held as strings, and values that aren't pointers, such as those of
+// flag.Func, are shared.
+func Clone() *FlagSet {
+	return defaultSet.Clone()
+}
+
+// Clone returns an independent copy of the flag set and its commands.
+// It must be called on the top-level flag set. See the package-level
+// Clone.
+func (fs *FlagSet) Clone() *FlagSet {
+	return fs.clone(nil)
+}
+
+func (fs *FlagSet) clone(parent *FlagSet) *FlagSet {
+	c := *fs
+	c.FlagSet = flag.NewFlagSet(fs.Name(), fs.ErrorHandling())
+	c.FlagSet.SetOutput(fs.Output())
+	// Method values have the same code pointer for any receiver.
+	if fs.Usage == nil || reflect.ValueOf(fs.Usage).Pointer() == reflect.ValueOf(fs.defaultUsage).Pointer() {
+		c.Usage = c.defaultUsage
+	}
+	c.parent = parent
+	c.selected = nil
+	c.arguments = nil
+
+	c.progNameAliases = slices.Clone(fs.progNameAliases)
+	c.defaultConfig = slices.Clone(fs.defaultConfig)
+	c.searchPaths = slices.Clone(fs.searchPaths)
+	c.globalPatterns = slices.Clone(fs.globalPatterns)
+	c.userPatterns = slices.Clone(fs.userPatterns)
+	c.precedence = slices.Clone(fs.precedence)
+	c.stdin = nil
+	c.unusedKeys, c.filesUsed = nil, nil
+	c.userConfigSkipped = false
+
+	c.sources = make(map[string]string)
+	c.aliases = maps.Clone(fs.aliases)
+	c.deprecated = maps.Clone(fs.deprecated)
+	c.migrations = maps.Clone(fs.migrations)
+	c.warnedDeprecated = make(map[string]bool)
+	c.sensitive = maps.Clone(fs.sensitive)
+	c.hidden = maps.Clone(fs.hidden)
+	c.grouped = maps.Clone(fs.grouped)
+	c.pendingRefs = maps.Clone(fs.pendingRefs)
+	c.keyring = maps.Clone(fs.keyring)
+	c.probes = slices.Clone(fs.probes)
+	c.requiredTogether = nil
+	for _, names := range fs.requiredTogether {
+		c.requiredTogether = append(c.requiredTogether, slices.Clone(names))
+	}
+	c.annotations = nil
+	for name, a := range fs.annotations {
+		if c.annotations == nil {
+			c.annotations = make(map[string]map[string]string)
+		}
+		c.annotations[name] = maps.Clone(a)
+	}
+	c.configOnly = maps.Clone(fs.configOnly)
+	c.cliOnly = maps.Clone(fs.cliOnly)
+	c.mutable = maps.Clone(fs.mutable)
+	c.onChange = slices.Clone(fs.onChange)
+	c.configArgs, c.configArgsFrom = nil, ""
+	c.envVars = maps.Clone(fs.envVars)
+	c.reloadable = maps.Clone(fs.reloadable)
+	c.listModes = maps.Clone(fs.listModes)
+	c.listDelims = maps.Clone(fs.listDelims)
+
+	fs.VisitAll(func(f *flag.Flag) {
+		v := f.Value
+		if reflect.TypeOf(v).Kind() == reflect.Pointer {
+			v = newValue(f)
+		}
+		c.FlagSet.Var(v, f.Name, f.Usage)
+		c.FlagSet.Lookup(f.Name).DefValue = f.DefValue
+	})
+	c.groups = nil
+	for _, g := range fs.groups {
+		cg := &flagGroup{title: g.title}
+		for _, f := range g.flags {
+			cg.flags = append(cg.flags, c.FlagSet.Lookup(f.Name))
+		}
+		c.groups = append(c.groups, cg)
+	}
+	c.commands = make(map[string]*FlagSet)
+	for name, cmd := range fs.commands {
+		c.commands[name] = cmd.clone(&c)
+	}
+	return &c
+}
@@ -11,10 +11,88 @@
 // 	http=localhost:8080
 //	play=false
 //
+// As on the command line, a boolean flag may be given without a value to
+// set it to true, so "play" is the same as "play=true".
+//
+// A boolean flag may be turned off by prefixing its name with "no-",
+// so "no-play" is the same as "play=false".
+//
+// Whitespace around names and values is ignored. Values may be enclosed
+// in double quotes, which allow escape sequences as in Go string literals,
+// or in single quotes, which preserve the value literally:
+//
+//	greeting="  hello # world\n"
+//	path='C:\data'
+//
+// Values may refer to values of other flags, which are substituted after
+// parsing all configuration files and the command line:
+//
+//	pidfile=${flag:data-dir}/app.pid
+//
+// A line ending with a backslash continues on the next line, with the
+// leading whitespace of the next line removed:
+//
+//	hosts=alpha.example.com,\
+//		beta.example.com
+//
+// Entries following a "[profile name]" line are applied, overriding
+// entries outside of profile sections, only when the named profile is
+// selected with SetProfile, the flag enabled by EnableProfileFlag, or
+// the PROGNAME_PROFILE environment variable:
+//
+//	http=localhost:8080
+//
+//	[profile production]
+//	http=:80
+//
+// Similarly, entries following a "[host pattern]" line are applied only
+// on machines whose host name matches the pattern, such as "web01" or
+// "web*", overriding entries outside of host sections and in profiles.
+//
+// Entries following a "[command name]" line are applied only to the flag
+// set of the named command, defined with Command, after entries outside of
+// sections.
+//
+// Entries following a "[prefix]" line, such as "[server]", set flags
+// with names starting with the prefix and a dot: the key "port" in the
+// "[server]" section refers to the flag "server.port". Such flags are
+// defined, for example, with NewNamespace or Register.
+//
+// Blank lines and lines starting with "#" are ignored. WriteExampleConfig
+// generates a commented configuration file listing all defined flags.
+//
+// Configuration files may also be written in TOML, JSON, or as Java
+// properties, if selected with SetConfigFormat or by the ".toml", ".json",
+// or ".properties" extension of the file name. Keys in TOML tables and
+// nested JSON objects are mapped to dotted flag names: "port" in the
+// "[server]" table sets the flag "server.port". YAML files can be loaded
+// after enabling them with SetYAMLDecoder, and decoders for other formats
+// can be added with RegisterFormat.
+//
+// Unless the format is selected, the configuration file name may have
+// any of the extensions of known formats or ".conf" for the native format,
+// such as "$HOME/.progname.toml"; it's an error if several such files
+// exist. Files without a known extension that start with "{" are decoded
+// as JSON.
+//
 // The order of loading configurations is:
 //
-// 	/etc/progname
-//	$HOME/.progname
+//	default configuration, if set with SetDefaultConfig
+// 	/etc/progname or /etc/progname/config
+//	$HOME/.progname or $HOME/.config/progname/config
+//	.env file, if set with SetDotEnvFile
+//
+// Of the alternative paths, the first existing file is loaded. The paths
+// of configuration files can be changed with SetConfigPatterns or
+// SetSearchPaths, and the order of loading with SetPrecedence, which can
+// also enable setting flags from environment variables.
+// EnableConfigFlag defines a flag naming the file to load instead of the
+// user configuration file, or "-" to read it from the standard input. The
+// PROGNAME_CONFIG environment variable can list files that replace or
+// extend the default ones; see SetConfigEnv. PROGNAME_CONFIG_PATH can
+// replace the whole search order; see SetSearchPaths. Setting PROGNAME_SKIP_CONFIG
+// to a true value, such as 1, skips loading of all configuration files, so
+// that the program runs with only explicitly given arguments.
 //
 // These files are parsed before command-line arguments, so real arguments
 // override flags from configuration file.
@@ -41,43 +119,54 @@
 //	}
 //
 // If your program overwrites Usage variable, call SetUsage() instead.
+//
+// Flag sets other than the default one, which read configuration files
+// in the same way, are created with New. NewFlagSet still returns a
+// *flag.FlagSet of the flag package, which doesn't read them.
 package conflag
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
-	"os/user"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
 
 // Lookup returns the Flag structure of the named command-line flag,
-// returning nil if none exists.
+// returning nil if none exists. The name may be an alias.
 func Lookup(name string) *flag.Flag {
 	return defaultSet.Lookup(name)
 }
 
-// Set sets the value of the named command-line flag.
+// Set sets the value of the named command-line flag. The name may be an alias.
 func Set(name, value string) error {
 	return defaultSet.Set(name, value)
 }
 
 // PrintDefaults prints to standard error the default values of all defined command-line flags.
+// Default values of sensitive flags are masked.
 func PrintDefaults() {
 	defaultSet.PrintDefaults()
 }
 
-// SetUsage sets underlying flag Usage variable.
+// SetUsage sets the function called to print usage information when an
+// error occurs while parsing flags. For compatibility, it also sets the
+// Usage variable of the flag package.
 func SetUsage(usage func()) {
+	defaultSet.Usage = usage
 	flag.Usage = usage
 }
 
-// Usage calls the underlying flag Usage function.
+// Usage prints usage information. By default, it prints the program
+// name, the default values of all flags, and the configuration files
+// that are consulted.
 func Usage() {
-	flag.Usage()
+	defaultSet.Usage()
 }
 
 // NFlag returns the number of command-line flags that have been set.
@@ -204,100 +293,701 @@ func Var(value flag.Value, name string, usage string) {
 // UserConfigFilePath returns user configuration file path ($HOME/.progname).
 // If program name is not set, returns an empty string.
 func UserConfigFilePath() string {
-	if progName == "" {
-		return ""
-	}
-	//TODO Proper Windows support.
-	u, err := user.Current()
-	if err != nil {
-		return ""
-	}
-	return filepath.Join(u.HomeDir, "."+progName)
+	return defaultSet.UserConfigFilePath()
 }
 
-// GlobalConfigFilePath returns user configuration file path (/etc/progname).
+// UserConfigFilePath returns user configuration file path ($HOME/.progname).
 // If program name is not set, returns an empty string.
+func (fs *FlagSet) UserConfigFilePath() string {
+	path, _, _ := fs.findLayerFile(LayerUser)
+	return path
+}
+
+// GlobalConfigFilePath returns global configuration file path (/etc/progname,
+// unless changed with SetSysConfDir). If program name is not set, returns
+// an empty string.
 func GlobalConfigFilePath() string {
-	if progName == "" {
-		return ""
+	return defaultSet.GlobalConfigFilePath()
+}
+
+// GlobalConfigFilePath returns global configuration file path (/etc/progname).
+// If program name is not set, returns an empty string.
+func (fs *FlagSet) GlobalConfigFilePath() string {
+	path, _, _ := fs.findLayerFile(LayerGlobal)
+	return path
+}
+
+// defaultSysConfDir is the directory of global configuration files. It can
+// be changed at build time for installations under a different prefix:
+//
+//	go build -ldflags "-X github.com/dchest/conflag.defaultSysConfDir=/usr/local/etc"
+var defaultSysConfDir = "/etc"
+
+// SetSysConfDir sets the directory of the global configuration file, which
+// is /etc by default, so that the file is loaded from, for example,
+// /usr/local/etc/progname. The PROGNAME_SYSCONFDIR environment variable
+// takes precedence.
+func SetSysConfDir(dir string) {
+	defaultSet.SetSysConfDir(dir)
+}
+
+// SetSysConfDir sets the directory of the global configuration file.
+// See the package-level SetSysConfDir.
+func (fs *FlagSet) SetSysConfDir(dir string) {
+	fs.root().sysConfDir = dir
+}
+
+// globalConfigDir returns the directory of the global configuration file.
+func (fs *FlagSet) globalConfigDir() string {
+	root := fs.root()
+	if dir := os.Getenv(root.envName("SYSCONFDIR")); dir != "" {
+		return dir
+	}
+	if root.sysConfDir != "" {
+		return root.sysConfDir
 	}
-	//TODO Proper Windows support.
-	return filepath.Join("/etc/", progName)
+	return defaultSysConfDir
+}
+
+// entry is a flag setting read from a configuration file.
+type entry struct {
+	name     string
+	value    string
+	hasValue bool   // false for lines without "=", such as "verbose"
+	literal  bool   // value was enclosed in single quotes
+	file     string // configuration file path
+	line     int    // line number, or 0 if unknown
+	section  string // section name, such as "profile production"
+	nextElem bool   // element of an array other than the first
+}
+
+func (e *entry) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s: %s", e.location(), fmt.Sprintf(format, args...))
+}
+
+// location returns the file and line of the entry.
+func (e *entry) location() string {
+	if e.line == 0 {
+		// Decoders of some formats don't report line numbers.
+		return e.file
+	}
+	return fmt.Sprintf("%s:%d", e.file, e.line)
+}
+
+// invalidValue returns an error for a failure to set the flag from the entry.
+func (e *entry) invalidValue(fs *FlagSet, f *flag.Flag, value string, err error) error {
+	if fs.IsSensitive(f.Name) {
+		return e.errorf("invalid value for flag -%s: %v", e.name, err)
+	}
+	return e.errorf("invalid value %q for flag -%s: %v", value, e.name, err)
+}
+
+// utf8BOM is the byte order mark, which some editors put at the beginning
+// of UTF-8 files.
+const utf8BOM = "\uFEFF"
+
+// SetMaxLineLength sets the maximum length of a line in configuration
+// files, including line continuations, which is 1 MiB by default.
+// Configuration files with longer lines are rejected.
+func SetMaxLineLength(n int) {
+	defaultSet.SetMaxLineLength(n)
+}
+
+// SetMaxLineLength sets the maximum length of a line in configuration
+// files, including line continuations, which is 1 MiB by default.
+// Configuration files with longer lines are rejected.
+func (fs *FlagSet) SetMaxLineLength(n int) {
+	fs.root().maxLineLength = n
 }
 
-// readConfig reads configuration file and returns a slice
-// of arguments-formatted strings.
-func readConfig(filename string) (args []string) {
-	f, err := os.Open(filename)
+// readConfig reads configuration file and returns a slice of entries.
+// The file named "-" is the standard input.
+func (fs *FlagSet) readConfig(filename string) (entries []entry, err error) {
+	if filename == "-" {
+		data, err := fs.readStdin()
+		if err != nil {
+			return nil, fmt.Errorf("error reading config from standard input: %s", err)
+		}
+		fs.markUsed(filename)
+		fs.tracef("read %s", stdinName)
+		return fs.decodeConfig(stdinName, data)
+	}
+	data, err := fs.readFile(filename)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// Config doesn't exist, not an error.
-			return nil
+			fs.logf(LevelDebug, "config file %s not found", filename)
+			return nil, nil
 		}
-		fmt.Fprintf(os.Stderr, "error opening config file %q: %s", filename, err)
-		os.Exit(2)
+		return nil, fs.unreadable(fmt.Errorf("error opening config file %q: %s", filename, err))
 	}
-	defer f.Close()
+	fs.markUsed(filename)
+	fs.tracef("read %s", filename)
+	return fs.decodeConfig(filename, data)
+}
+
+// decodeConfig returns entries of the configuration in the format of
+// the named file.
+func (fs *FlagSet) decodeConfig(filename string, data []byte) ([]entry, error) {
+	format := fs.configFormat(filename)
+	if format == "" && bytes.HasPrefix(bytes.TrimSpace(bytes.TrimPrefix(data, []byte(utf8BOM))), []byte("{")) {
+		// Native configuration can't start with "{", but JSON can.
+		format = "json"
+	}
+	if decode := formats[format]; decode != nil {
+		return decode(filename, data)
+	}
+	return fs.decodeNative(filename, data)
+}
 
-	// Read each line, prefix it with "-" and put into args.
-	scanner := bufio.NewScanner(f)
+// decodeNative returns entries of the configuration file in the native
+// format.
+func (fs *FlagSet) decodeNative(filename string, data []byte) (entries []entry, err error) {
+	maxLineLength := fs.root().maxLineLength
+
+	// Read each line, skipping blank lines and comments. The line
+	// has the same format as a command-line flag without the leading
+	// dashes, which are optional.
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, maxLineLength)
+	n := 0
+	section := ""
 	for scanner.Scan() {
-		args = append(args, "-"+scanner.Text())
+		n++
+		line := scanner.Text()
+		if n == 1 {
+			line = strings.TrimPrefix(line, utf8BOM)
+		}
+		// Trimming also removes carriage returns of CRLF line endings.
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			if section, err = parseSectionHeader(line); err != nil {
+				return nil, fmt.Errorf("%s:%d: %s", filename, n, err)
+			}
+			continue
+		}
+		e := entry{file: filename, line: n, section: section}
+		// Join lines ending with a backslash with the following line.
+		for strings.HasSuffix(line, "\\") && scanner.Scan() {
+			n++
+			line = line[:len(line)-1] + strings.TrimSpace(scanner.Text())
+			if len(line) > maxLineLength {
+				return nil, e.errorf("line is longer than %d bytes", maxLineLength)
+			}
+		}
+		line = strings.TrimSuffix(line, "\\")
+		var key string
+		key, e.value, e.hasValue = strings.Cut(line, "=")
+		if e.name, err = nativeKey(key); err != nil {
+			return nil, e.errorf("%s", err)
+		}
+		e.literal = strings.HasPrefix(strings.TrimSpace(e.value), "'")
+		if e.value, err = unquoteValue(e.value); err != nil {
+			return nil, e.errorf("%s", err)
+		}
+		entries = append(entries, e)
 	}
 	if err := scanner.Err(); err != nil {
-		fmt.Fprintf(os.Stderr, "error parsing config file %q: %s", filename, err)
-		os.Exit(2)
+		if err == bufio.ErrTooLong {
+			return nil, fmt.Errorf("%s:%d: line is longer than %d bytes", filename, n+1, maxLineLength)
+		}
+		return nil, fmt.Errorf("error parsing config file %q: %s", filename, err)
+	}
+	return entries, nil
+}
+
+// nativeKey returns the flag name from the key, which may be preceded by
+// one or two dashes, as on the command line.
+func nativeKey(key string) (string, error) {
+	key = strings.TrimSpace(key)
+	switch key {
+	case "":
+		return "", errors.New("missing flag name")
+	case "-":
+		return "", errors.New(`missing flag name after "-"`)
+	case "--":
+		return "", errors.New(`"--" terminates flags on the command line and is not allowed in configuration files`)
+	}
+	name := strings.TrimPrefix(strings.TrimPrefix(key, "-"), "-")
+	if name[0] == '-' {
+		return "", fmt.Errorf("bad flag syntax: %s", key)
+	}
+	return name, nil
+}
+
+// unquoteValue returns the value with surrounding whitespace removed. If
+// the value is enclosed in double quotes, they are removed and escape
+// sequences are interpreted as in Go string literals. If it is enclosed in
+// single quotes, they are removed and the value is taken literally. A
+// quoted value may be followed by a comment.
+func unquoteValue(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || (s[0] != '"' && s[0] != '\'') {
+		return s, nil
+	}
+	end := -1
+	for i := 1; i < len(s); i++ {
+		if s[0] == '"' && s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == s[0] {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return "", fmt.Errorf("missing closing quote in value %s", s)
+	}
+	if rest := strings.TrimSpace(s[end+1:]); rest != "" && rest[0] != '#' {
+		return "", fmt.Errorf("unexpected %q after quoted value", rest)
+	}
+	if s[0] == '\'' {
+		return s[1:end], nil
+	}
+	v, err := strconv.Unquote(s[:end+1])
+	if err != nil {
+		return "", fmt.Errorf("invalid quoted value %s", s[:end+1])
+	}
+	return v, nil
+}
+
+// quoteValue returns the value in the form suitable for writing into
+// a configuration file, quoting it if it wouldn't be read back unchanged.
+func quoteValue(s string) string {
+	if s == strings.TrimSpace(s) && strconv.CanBackquote(s) &&
+		!strings.HasPrefix(s, `"`) && !strings.HasPrefix(s, "'") &&
+		!strings.HasSuffix(s, `\`) {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+// SetExpandEnv enables or disables expansion of environment variables in
+// configuration file values. When enabled, $VAR and ${VAR} are replaced
+// with the values of the corresponding environment variables, and $$ is
+// replaced with a literal dollar sign. Values in single quotes are not
+// expanded.
+func SetExpandEnv(enable bool) {
+	defaultSet.SetExpandEnv(enable)
+}
+
+// SetExpandEnv enables or disables expansion of environment variables in
+// configuration file values, as described in the package-level SetExpandEnv.
+func (fs *FlagSet) SetExpandEnv(enable bool) {
+	fs.root().expandEnv = enable
+}
+
+// expandValue expands environment variables in the value.
+func expandValue(s string) string {
+	return os.Expand(s, func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		if strings.HasPrefix(name, "flag:") {
+			// Keep references to flags.
+			return "${" + name + "}"
+		}
+		return os.Getenv(name)
+	})
+}
+
+// configFilePaths returns paths of configuration files, including the
+// .env file, in the order of loading. If there are several candidates for
+// a file, it returns the first of them and an error.
+func (fs *FlagSet) configFilePaths() (paths []string, err error) {
+	for _, layer := range fs.root().layers() {
+		p, lerr := fs.layerFilePaths(layer)
+		if lerr != nil && err == nil {
+			err = lerr
+		}
+		paths = append(paths, p...)
 	}
 	return
 }
 
-// parseConfig parses configuration files.
-func parseConfigs() {
-	if args := readConfig(GlobalConfigFilePath()); args != nil {
-		defaultSet.Parse(args)
+// layerFilePaths returns paths of configuration files of the layer.
+// If there are several candidates for a file, it returns the first of
+// them and an error.
+func (fs *FlagSet) layerFilePaths(layer Layer) (paths []string, err error) {
+	root := fs.root()
+	envPaths, extend := fs.configEnvPaths()
+	searchPaths := fs.searchPathList()
+	switch layer {
+	case LayerGlobal:
+		if searchPaths != nil || root.noGlobalConfig || envPaths != nil && !extend {
+			return nil, nil
+		}
+		return fs.patternFilePaths(layer)
+	case LayerUser:
+		if path, ok := fs.configFileArg(); ok {
+			return []string{path}, nil
+		}
+		if envPaths != nil && !extend {
+			return fs.findConfigFiles(envPaths)
+		}
+		if searchPaths != nil {
+			paths, err = fs.findConfigFiles(searchPaths)
+		} else if !root.noUserConfig {
+			paths, err = fs.patternFilePaths(layer)
+			var herr *homeError
+			if errors.As(err, &herr) {
+				err = fs.missingHome(herr)
+			}
+		}
+		if err == nil && envPaths != nil {
+			var more []string
+			more, err = fs.findConfigFiles(envPaths)
+			paths = append(paths, more...)
+		}
+		return paths, err
+	case LayerDotEnv:
+		if root.dotEnvFile != "" {
+			return []string{root.dotEnvFile}, nil
+		}
+	}
+	return nil, nil
+}
+
+// patternFilePaths returns the path of the configuration file of the
+// layer found by its patterns.
+func (fs *FlagSet) patternFilePaths(layer Layer) ([]string, error) {
+	path, alias, err := fs.findLayerFile(layer)
+	if path == "" {
+		return nil, err
+	}
+	if alias != "" {
+		fs.logf(LevelWarn, "%s: configuration file for the old program name %s is deprecated, rename it for %s", path, alias, fs.ProgName())
+	}
+	return []string{path}, err
+}
+
+// findConfigFiles returns paths of configuration files found for the
+// given paths, which may start with "~/" and omit the extension.
+func (fs *FlagSet) findConfigFiles(bases []string) (paths []string, err error) {
+	for _, path := range bases {
+		if p, err := ExpandHome(path); err == nil {
+			path = p
+		}
+		path, _, ferr := fs.findConfigFile(path)
+		if ferr != nil && err == nil {
+			err = ferr
+		}
+		paths = append(paths, path)
 	}
-	if args := readConfig(UserConfigFilePath()); args != nil {
-		defaultSet.Parse(args)
+	return paths, err
+}
+
+// UnknownKeyPolicy defines what happens when a configuration file
+// contains a key that doesn't refer to any defined flag.
+type UnknownKeyPolicy int
+
+// These constants cause parsing to behave as described if a configuration
+// file contains an unknown key.
+const (
+	UnknownKeyError  UnknownKeyPolicy = iota // Report an error.
+	UnknownKeyWarn                           // Print a warning and skip the key.
+	UnknownKeyIgnore                         // Silently skip the key.
+)
+
+// SetUnknownKeyPolicy sets the policy for unknown keys in configuration
+// files. The default is UnknownKeyError, which is useful for catching typos,
+// while other policies allow sharing a configuration file between related
+// programs.
+func SetUnknownKeyPolicy(policy UnknownKeyPolicy) {
+	defaultSet.SetUnknownKeyPolicy(policy)
+}
+
+// SetUnknownKeyPolicy sets the policy for unknown keys in configuration
+// files. The default is UnknownKeyError.
+func (fs *FlagSet) SetUnknownKeyPolicy(policy UnknownKeyPolicy) {
+	fs.root().unknownKeyPolicy = policy
+}
+
+// UnreadableFilePolicy defines what happens when a configuration file
+// exists but can't be read, for example, because of its permissions.
+type UnreadableFilePolicy int
+
+// These constants cause parsing to behave as described if a configuration
+// file can't be read.
+const (
+	UnreadableFileError  UnreadableFilePolicy = iota // Report an error.
+	UnreadableFileWarn                               // Print a warning and skip the file.
+	UnreadableFileIgnore                             // Silently skip the file.
+)
+
+// SetUnreadableFilePolicy sets the policy for configuration files that
+// exist but can't be read. The default is UnreadableFileError; other
+// policies are useful when, for example, /etc/progname is readable only
+// by root, but the program also runs as other users.
+func SetUnreadableFilePolicy(policy UnreadableFilePolicy) {
+	defaultSet.SetUnreadableFilePolicy(policy)
+}
+
+// SetUnreadableFilePolicy sets the policy for configuration files that
+// exist but can't be read. The default is UnreadableFileError.
+func (fs *FlagSet) SetUnreadableFilePolicy(policy UnreadableFilePolicy) {
+	fs.root().unreadableFilePolicy = policy
+}
+
+// unreadable handles the error reading the existing configuration file
+// according to the policy, returning nil if the file should be skipped.
+func (fs *FlagSet) unreadable(err error) error {
+	switch fs.root().unreadableFilePolicy {
+	case UnreadableFileWarn:
+		fs.logf(LevelWarn, "%s", err)
+		return nil
+	case UnreadableFileIgnore:
+		fs.logf(LevelDebug, "%s", err)
+		return nil
 	}
+	return err
+}
+
+// KeyInfo describes a key in a configuration file.
+type KeyInfo struct {
+	Key     string // key as written, possibly with a section prefix
+	File    string // configuration file path
+	Line    int    // line number, or 0 if unknown
+	Section string // section name, such as "profile production"
+}
+
+// UnusedConfigKeys returns keys in configuration files that didn't refer
+// to any defined flag and were skipped by Parse according to the policy
+// set with SetUnknownKeyPolicy, so that the program can warn about typos.
+func UnusedConfigKeys() []KeyInfo {
+	return defaultSet.UnusedConfigKeys()
+}
+
+// UnusedConfigKeys returns keys in configuration files that didn't refer
+// to any defined flag.
+func (fs *FlagSet) UnusedConfigKeys() []KeyInfo {
+	return append([]KeyInfo(nil), fs.root().unusedKeys...)
 }
 
-// Parse parses the command-line flags from os.Args[1:].  Must be called
-// after all flags are defined and before flags are accessed by the program.
+// applyEntry sets the flag from the configuration file entry.
+func (fs *FlagSet) applyEntry(e entry) error {
+	if e.name == "" {
+		return e.errorf("missing flag name")
+	}
+	if e.name[0] == '-' || strings.ContainsAny(e.name, " \t") {
+		return e.errorf("bad flag syntax: %s", e.name)
+	}
+	if fs.isArgsEntry(e) {
+		return fs.addConfigArg(e)
+	}
+	raw := e.value
+	name := fs.normalizeKey(e.name)
+	f := fs.Lookup(name)
+	if f == nil && !e.hasValue {
+		// Negated boolean flag, such as "no-verbose".
+		if f = fs.lookupNegated(e.name); f != nil {
+			e.hasValue, e.value = true, "false"
+			name = f.Name
+		}
+	}
+	if f == nil {
+		if newName, ok := fs.migratedName(e.name); ok {
+			fs.logMigrated(e, newName)
+			f, name = fs.Lookup(newName), newName
+		}
+	}
+	// Trace after resolving the key, so that values of sensitive flags
+	// set with normalized or migrated keys are masked.
+	traceName := e.name
+	if f != nil {
+		traceName = f.Name
+	}
+	fs.tracef("%s: read key %s=%s", e.location(), e.name, fs.traceValue(traceName, raw))
+	if f == nil {
+		if fs.belongsElsewhere(e) {
+			fs.tracef("%s: skip key %s for another command", e.location(), e.name)
+			return nil
+		}
+		root := fs.root()
+		if root.unknownKeyPolicy == UnknownKeyError {
+			return e.errorf("flag provided but not defined: -%s", e.name)
+		}
+		if root.unknownKeyPolicy == UnknownKeyWarn {
+			fs.logf(LevelWarn, "%s", e.errorf("unknown flag -%s", e.name))
+		}
+		root.unusedKeys = append(root.unusedKeys, KeyInfo{e.name, e.file, e.line, e.section})
+		fs.tracef("%s: skip unknown key %s", e.location(), e.name)
+		return nil
+	}
+	if fs.cliOnly[f.Name] {
+		return e.errorf("flag -%s can only be set on the command line", e.name)
+	}
+	value := e.value
+	if fs.root().expandEnv && !e.literal {
+		value = expandValue(value)
+	}
+	if !e.hasValue {
+		if !isBoolFlag(f) {
+			return e.errorf("flag needs an argument: -%s", e.name)
+		}
+		value = "true"
+	}
+	if !e.literal && hasFlagRefs(value) {
+		// Resolve after all sources are parsed.
+		e.name, e.value = name, value
+		fs.pendingRefs[f.Name] = e
+		fs.tracef("%s: defer -%s=%s until references are resolved", e.location(), f.Name, fs.traceValue(f.Name, value))
+		return nil
+	}
+	if err := fs.setFlag(f, name, value, e.file); err != nil {
+		return e.invalidValue(fs, f, value, err)
+	}
+	return nil
+}
+
+// belongsElsewhere reports whether the entry with a key unknown to the
+// flag set is meant for another set in the command hierarchy: either
+// a command's set, or, for entries outside of command sections, the
+// parent set.
+func (fs *FlagSet) belongsElsewhere(e entry) bool {
+	if fs.parent != nil {
+		return !strings.HasPrefix(e.section, "command ")
+	}
+	for _, c := range fs.commands {
+		if c.Lookup(c.normalizeKey(e.name)) != nil || c.lookupNegated(e.name) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// loadLayer sets flags from the configuration layer other than the
+// command line.
+func (fs *FlagSet) loadLayer(layer Layer) error {
+	fs.tracef("load layer %s", layer)
+	if layer == LayerEnv {
+		if err := fs.applyEntries(fs.envEntries()); err != nil {
+			return fs.failConfig(err)
+		}
+		return nil
+	}
+	if layer == LayerDefault {
+		if data := fs.root().defaultConfig; data != nil {
+			if err := fs.applyConfig(defaultConfigName, data); err != nil {
+				return fs.failConfig(err)
+			}
+		}
+		return nil
+	}
+	if layer == LayerRegistry {
+		entries, err := fs.registryEntries()
+		if err == nil {
+			err = fs.applyEntries(entries)
+		}
+		if err != nil {
+			return fs.failConfig(err)
+		}
+		return nil
+	}
+	if fs.configDisabled() {
+		return nil
+	}
+	paths, err := fs.layerFilePaths(layer)
+	if err != nil {
+		return fs.failConfig(err)
+	}
+	for _, filename := range paths {
+		var entries []entry
+		if layer == LayerDotEnv {
+			entries, err = fs.readDotEnv(filename)
+		} else {
+			entries, err = fs.readConfig(filename)
+		}
+		if err == nil {
+			entries, err = fs.checkDuplicates(entries)
+		}
+		if err == nil && layer != LayerDotEnv {
+			entries, err = fs.selectSections(entries)
+		}
+		if err == nil {
+			err = fs.applyEntries(entries)
+		}
+		if err != nil {
+			return fs.failConfig(err)
+		}
+	}
+	return nil
+}
+
+// applyEntries sets flags from the entries.
+func (fs *FlagSet) applyEntries(entries []entry) error {
+	for _, e := range entries {
+		if err := fs.applyEntry(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Parse parses the command-line flags from os.Args[1:], or from the
+// arguments set with SetArgs.  Must be called after all flags are defined
+// and before flags are accessed by the program.
 func Parse() {
-	// Parse config first if we have progName set.
-	if progName != "" {
-		parseConfigs()
+	args := os.Args[1:]
+	if defaultArgs != nil {
+		args = defaultArgs
+	}
+	// Ignore errors; defaultSet is set for ExitOnError, unless it was
+	// replaced by ResetForTesting.
+	defaultSet.Parse(args)
+}
+
+// SetArgs sets the arguments, not including the program name, parsed by
+// Parse instead of os.Args[1:], so that programs embedding others, such
+// as test harnesses, plugin loaders, or REPLs, can control them without
+// changing os.Args. Passing nil restores the default; pass an empty slice
+// to parse no arguments.
+func SetArgs(args []string) {
+	if args != nil {
+		args = append([]string{}, args...)
 	}
-	// Now parse the command line.
-	// Ignore errors; defaultSet is set for ExitOnError.
-	defaultSet.Parse(os.Args[1:])
+	defaultArgs = args
 }
 
+// defaultArgs are the arguments set with SetArgs.
+var defaultArgs []string
+
 // Parsed returns true if the command-line flags have been parsed.
 func Parsed() bool {
 	return defaultSet.Parsed()
 }
 
-var progName string
-
 // SetProgName sets program name, which is used for locating configuration file,
 // and outputting usage or error information.
 //
 // If program name is not set, the package won't use configuration file, only
 // command line arguments.
 func SetProgName(name string) {
-	if strings.ContainsRune(progName, filepath.Separator) {
-		fmt.Println(filepath.Clean(progName))
-		panic("conflag: SetProgName called with bad program name")
-	}
-	progName = name
+	defaultSet.SetProgName(name)
+}
+
+// Command returns the flag set for the named subcommand of the program.
+// See FlagSet.Command.
+func Command(name string) *FlagSet {
+	return defaultSet.Command(name)
+}
+
+// Selected returns the flag set of the command selected by the command-line
+// arguments, or nil if no command was selected.
+func Selected() *FlagSet {
+	return defaultSet.Selected()
 }
 
 // The default set of command-line flags, parsed from os.Args.
-var defaultSet = NewFlagSet(os.Args[0], flag.ExitOnError)
+var defaultSet = New(os.Args[0], flag.ExitOnError)
 
-// NewFlagSet returns a new, empty flag set with the specified name and
-// error handling property.
+// NewFlagSet returns a new, empty flag set of the flag package with the
+// specified name and error handling property. It doesn't read
+// configuration files; use New to create a flag set that does.
 func NewFlagSet(name string, errorHandling flag.ErrorHandling) *flag.FlagSet {
 	return flag.NewFlagSet(name, errorHandling)
 }
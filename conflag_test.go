@@ -0,0 +1,1901 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// newTestSet returns a flag set that continues after errors and discards
+// its output.
+func newTestSet() *FlagSet {
+	fs := New("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+func TestTraceMasksResolvedKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		setup  func(fs *FlagSet)
+	}{
+		{"normalized", "api_key=hunter2\n", func(fs *FlagSet) {
+			fs.SetNormalizeFunc(NormalizeName)
+		}},
+		{"migrated", "old-pw=hunter2\n", func(fs *FlagSet) {
+			fs.MigrateKey("old-pw", "password")
+		}},
+	}
+	for _, tt := range tests {
+		fs := newTestSet()
+		fs.String("api-key", "", "API key")
+		fs.String("password", "", "password")
+		fs.MarkSensitive("api-key", "password")
+		tt.setup(fs)
+		var trace bytes.Buffer
+		fs.EnableTrace(&trace)
+		if err := fs.ParseReader(strings.NewReader(tt.config)); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if strings.Contains(trace.String(), "hunter2") {
+			t.Errorf("%s: trace reveals sensitive value:\n%s", tt.name, trace.String())
+		}
+		if !strings.Contains(trace.String(), "read key") {
+			t.Errorf("%s: trace doesn't include read key:\n%s", tt.name, trace.String())
+		}
+	}
+}
+
+func TestSaveSectionRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.conf")
+	config := "# settings\nverbose\n\n[server]\nport=80\nhost=localhost\n"
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fs := newTestSet()
+	verbose := fs.Bool("verbose", false, "verbose output")
+	port := fs.Int("server.port", 0, "port")
+	host := fs.String("server.host", "", "host")
+	if err := fs.ParseFS(os.DirFS(filepath.Dir(path)), "test.conf"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Set("server.port", "9090"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# settings\nverbose=true\n\n[server]\nport=9090\nhost=localhost\n"
+	if string(data) != want {
+		t.Errorf("saved file:\n%s\nwant:\n%s", data, want)
+	}
+
+	fs = newTestSet()
+	verbose = fs.Bool("verbose", false, "verbose output")
+	port = fs.Int("server.port", 0, "port")
+	host = fs.String("server.host", "", "host")
+	if err := fs.ParseFS(os.DirFS(filepath.Dir(path)), "test.conf"); err != nil {
+		t.Fatal(err)
+	}
+	if !*verbose || *port != 9090 || *host != "localhost" {
+		t.Errorf("after round trip: verbose=%v port=%d host=%q", *verbose, *port, *host)
+	}
+}
+
+func TestParseReaderDefersRefs(t *testing.T) {
+	fs := newTestSet()
+	a := fs.String("a", "", "a")
+	fs.String("b", "", "b")
+	if err := fs.ParseReader(strings.NewReader("a=${flag:b}/x\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse([]string{"-b=dir"}); err != nil {
+		t.Fatal(err)
+	}
+	if *a != "dir/x" {
+		t.Errorf("a = %q, want %q", *a, "dir/x")
+	}
+
+	fs = newTestSet()
+	a = fs.String("a", "", "a")
+	fs.String("b", "", "b")
+	fsys := fstest.MapFS{
+		"1.conf": {Data: []byte("a=${flag:b}/x\n")},
+		"2.conf": {Data: []byte("b=dir\n")},
+	}
+	if err := fs.ParseFS(fsys, "1.conf", "2.conf"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	if *a != "dir/x" {
+		t.Errorf("ParseFS: a = %q, want %q", *a, "dir/x")
+	}
+
+	fs = newTestSet()
+	fs.String("a", "", "a")
+	if err := fs.ParseReader(strings.NewReader("a=${flag:missing}\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse(nil); err == nil {
+		t.Error("expected error for reference to undefined flag")
+	}
+	if err := fs.Parse(nil); err != nil {
+		t.Errorf("reference kept after Parse: %v", err)
+	}
+}
+
+func TestParseReaderDefersConstraints(t *testing.T) {
+	fs := newTestSet()
+	fs.String("a", "", "a")
+	fs.String("b", "", "b")
+	fs.MarkRequiredTogether("a", "b")
+	if err := fs.ParseReader(strings.NewReader("a=1\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse([]string{"-b=2"}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDecodeNativeWindows(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{"bom", "\uFEFFa=1\nb=2\n", []string{"a=1", "b=2"}},
+		{"crlf", "a=1\r\n# comment\r\nb=\"x y\"\r\n", []string{"a=1", "b=x y"}},
+		{"bom and crlf", "\uFEFFa=1\r\nb=2\r\n", []string{"a=1", "b=2"}},
+		{"bom only line", "\uFEFF\r\na=1\r\n", []string{"a=1"}},
+		{"continuation", "a=1,\\\r\n  2\r\n", []string{"a=1,2"}},
+	}
+	fs := newTestSet()
+	for _, tt := range tests {
+		entries, err := fs.decodeNative("test.conf", []byte(tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.name+"="+e.value)
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSaveKeepsBOMAndCRLF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.conf")
+	config := "\uFEFF# settings\r\nport=80\r\nname=x\r\n"
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fs := newTestSet()
+	fs.Int("port", 0, "port")
+	fs.String("name", "", "name")
+	fs.Bool("verbose", false, "verbose output")
+	if err := fs.ParseFS(os.DirFS(filepath.Dir(path)), "test.conf"); err != nil {
+		t.Fatal(err)
+	}
+	fs.Set("port", "9090")
+	fs.Set("verbose", "true")
+	if err := fs.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "\uFEFF# settings\r\nport=9090\r\nname=x\r\nverbose=true\r\n"
+	if string(data) != want {
+		t.Errorf("saved file %q, want %q", data, want)
+	}
+}
+
+func TestRequiredTogetherIgnoresDefaultConfig(t *testing.T) {
+	fs := newTestSet()
+	fs.String("a", "", "a")
+	fs.String("b", "", "b")
+	fs.MarkRequiredTogether("a", "b")
+	fs.SetDefaultConfig([]byte("a=1\n"))
+	if err := fs.Parse(nil); err != nil {
+		t.Errorf("value from default config: %v", err)
+	}
+	if err := fs.Parse([]string{"-a=2"}); err == nil {
+		t.Error("expected error for -a set without -b")
+	}
+}
+
+func TestPrintFlagsContinueOnError(t *testing.T) {
+	null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer null.Close()
+	stdout := os.Stdout
+	os.Stdout = null
+	defer func() { os.Stdout = stdout }()
+	fs := newTestSet()
+	fs.EnableDumpFlag("print-config")
+	fs.EnableOverridesFlag("show-overrides")
+	for _, arg := range []string{"-print-config", "-show-overrides"} {
+		if err := fs.Parse([]string{arg}); err != flag.ErrHelp {
+			t.Errorf("Parse(%s) returned %v, want flag.ErrHelp", arg, err)
+		}
+		fs.Set(arg[1:], "false")
+	}
+}
+
+func TestWriteEnvMasksSensitive(t *testing.T) {
+	fs := newTestSet()
+	fs.String("password", "hunter2", "password")
+	fs.Int("max-conns", 10, "maximum number of connections")
+	fs.MarkSensitive("password")
+	var b strings.Builder
+	if err := fs.WriteEnv(&b, "APP_"); err != nil {
+		t.Fatal(err)
+	}
+	want := "APP_MAX_CONNS=10\n#APP_PASSWORD=*****\n"
+	if b.String() != want {
+		t.Errorf("WriteEnv wrote %q, want %q", b.String(), want)
+	}
+}
+
+func TestCheckValuesMasksSensitive(t *testing.T) {
+	fs := newTestSet()
+	fs.ExistingFile("key-file", "", "key file")
+	fs.MarkSensitive("key-file")
+	secret := filepath.Join(t.TempDir(), "hunter2.key")
+	err := fs.Parse([]string{"-key-file=" + secret})
+	if err == nil {
+		t.Fatal("expected error for missing file")
+	}
+	if strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("error reveals sensitive value: %v", err)
+	}
+}
+
+var expvarCount int
+
+// expvarName returns a name for publishing an expvar variable that's
+// unique even if the test is run several times.
+func expvarName(t *testing.T) string {
+	expvarCount++
+	return fmt.Sprintf("%s-%d", t.Name(), expvarCount)
+}
+
+func TestPublishExpvarReloads(t *testing.T) {
+	fs := newTestSet()
+	fs.Int("port", 0, "port")
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	name := expvarName(t)
+	fs.PublishExpvar(name)
+	if err := fs.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	fs.SetDefaultConfig([]byte("port=x\n"))
+	if err := fs.Reload(); err == nil {
+		t.Fatal("expected error for invalid value")
+	}
+	var v struct {
+		Reloads struct {
+			Succeeded, Failed int
+			Last              string
+		}
+	}
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Reloads.Succeeded != 1 || v.Reloads.Failed != 1 || v.Reloads.Last == "" {
+		t.Errorf("reloads = %+v, want 1 succeeded, 1 failed and the time", v.Reloads)
+	}
+}
+
+func TestProbeFreeSpace(t *testing.T) {
+	fs := newTestSet()
+	fs.String("data-dir", t.TempDir(), "data directory")
+	fs.AddProbe("data-dir", ProbeFreeSpace(1))
+	fs.AddProbe("data-dir", ProbeFreeSpace(1<<62))
+	results := fs.RunProbes()
+	if results[0].Err != nil {
+		t.Errorf("1 byte: %v", results[0].Err)
+	}
+	if _, err := freeSpace("."); err == nil && results[1].Err == nil {
+		t.Error("expected error for 4 EiB")
+	}
+}
+
+// formatEntries returns entries as "name=value", or "name" for entries
+// without a value.
+func formatEntries(entries []entry) []string {
+	var s []string
+	for _, e := range entries {
+		if e.hasValue {
+			s = append(s, e.name+"="+e.value)
+		} else {
+			s = append(s, e.name)
+		}
+	}
+	return s
+}
+
+type decodeTest struct {
+	name string
+	data string
+	want []string // nil if an error is expected
+}
+
+func runDecodeTests(t *testing.T, tests []decodeTest, decode func(data []byte) ([]entry, error)) {
+	t.Helper()
+	for _, tt := range tests {
+		entries, err := decode([]byte(tt.data))
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%s: expected error, got %q", tt.name, formatEntries(entries))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := formatEntries(entries); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeTOML(t *testing.T) {
+	tests := []decodeTest{
+		{"scalars", "port = 80\nverbose = true\nratio = 1_000.5\n", []string{"port=80", "verbose=true", "ratio=1000.5"}},
+		{"hex", "mask = 0xff\n", []string{"mask=255"}},
+		{"octal and binary", "mode = 0o755\nbits = 0b101\n", []string{"mode=493", "bits=5"}},
+		{"zeros", "a = 0\nb = -0\nc = 0.5\nd = 0e1\n", []string{"a=0", "b=0", "c=0.5", "d=0e1"}},
+		{"underscores", "n = -1_000\n", []string{"n=-1000"}},
+		{"leading zeros", "port = 010\n", nil},
+		{"signed leading zeros", "port = -010\n", nil},
+		{"leading zeros with underscore", "port = 0_10\n", nil},
+		{"float with leading zeros", "ratio = 01.5\n", nil},
+		{"signed hex", "mask = -0xff\n", nil},
+		{"comments", "# comment\nport = 80 # trailing\n", []string{"port=80"}},
+		{"basic string", `name = "a\tb \"c\" \u00e9"`, []string{"name=a\tb \"c\" é"}},
+		{"literal string", `path = 'C:\data'`, []string{`path=C:\data`}},
+		{"multi-line basic", "text = \"\"\"\nline1\nline2\"\"\"\n", []string{"text=line1\nline2"}},
+		{"multi-line literal", "text = '''\nC:\\a\n'''\n", []string{"text=C:\\a\n"}},
+		{"line ending backslash", "text = \"\"\"\na \\\n   b\"\"\"\n", []string{"text=a b"}},
+		{"table", "[server]\nport = 80\n", []string{"server.port=80"}},
+		{"dotted key", "server.port = 80\n", []string{"server.port=80"}},
+		{"quoted key", "\"a b\" = 1\n", []string{"a b=1"}},
+		{"array", "host = [\"a\", \"b\",\n  \"c\",]\n", []string{"host=a", "host=b", "host=c"}},
+		{"inline table", "db = {host = \"x\", port = 5432}\n", []string{"db.host=x", "db.port=5432"}},
+		{"date-time", "at = 1979-05-27 07:32:00Z\n", []string{"at=1979-05-27 07:32:00Z"}},
+		{"bom and crlf", "\uFEFFport = 80\r\nhost = \"x\"\r\n", []string{"port=80", "host=x"}},
+		{"missing value", "port =\n", nil},
+		{"missing equals", "port 80\n", nil},
+		{"bad value", "port = eighty\n", nil},
+		{"unterminated string", "name = \"abc\n", nil},
+		{"unterminated literal", "name = 'abc\n", nil},
+		{"bad escape", `name = "\q"`, nil},
+		{"short unicode escape", `name = "\u00"`, nil},
+		{"garbage after value", "port = 80 90\n", nil},
+		{"array of tables", "[[servers]]\n", nil},
+		{"nested array", "a = [[1]]\n", nil},
+		{"unterminated array", "a = [1, 2\n", nil},
+		{"unterminated header", "[server\n", nil},
+	}
+	runDecodeTests(t, tests, func(data []byte) ([]entry, error) {
+		return decodeTOML("test.toml", data)
+	})
+}
+
+func TestDecodeProperties(t *testing.T) {
+	tests := []decodeTest{
+		{"separators", "a=1\nb:2\nc 3\nd = 4\n", []string{"a=1", "b=2", "c=3", "d=4"}},
+		{"comments", "# comment\n! comment\n  a=1\n", []string{"a=1"}},
+		{"key only", "verbose\n", []string{"verbose"}},
+		{"empty value", "name=\n", []string{"name="}},
+		{"escapes", `a=x\ty\nz\u00e9\\`, []string{"a=x\ty\nzé\\"}},
+		{"escaped separator in key", `a\=b=c`, []string{"a=b=c"}},
+		{"continuation", "hosts=a,\\\n    b,\\\n    c\n", []string{"hosts=a,b,c"}},
+		{"escaped backslash at end", "path=C:\\\\\nb=1\n", []string{`path=C:\`, "b=1"}},
+		{"continuation at end of file", "a=1\\", []string{"a=1"}},
+		{"bom and crlf", "\uFEFFa=1\r\nb=2\r\n", []string{"a=1", "b=2"}},
+		{"short unicode escape", `a=\u00`, nil},
+		{"bad unicode escape", `a=\uzzzz`, nil},
+	}
+	runDecodeTests(t, tests, func(data []byte) ([]entry, error) {
+		return decodeProperties("test.properties", data)
+	})
+}
+
+func TestReadDotEnv(t *testing.T) {
+	tests := []decodeTest{
+		{"plain", "APP_PORT=80\nAPP_NAME=x\n", []string{"port=80", "name=x"}},
+		{"export", "export APP_PORT=80\n", []string{"port=80"}},
+		{"comments", "# comment\nAPP_NAME=a b # comment\n", []string{"name=a b"}},
+		{"double quotes", `APP_NAME="a # b\n"`, []string{"name=a # b\n"}},
+		{"single quotes", `APP_NAME='C:\data'`, []string{`name=C:\data`}},
+		{"unknown keys", "OTHER=1\nAPP_UNKNOWN=2\n", []string{}},
+		{"bom and crlf", "\uFEFFAPP_PORT=80\r\n", []string{"port=80"}},
+		{"missing equals", "APP_PORT\n", nil},
+		{"unterminated quote", `APP_NAME="abc`, nil},
+	}
+	dir := t.TempDir()
+	fs := newTestSet()
+	fs.SetProgName("app")
+	fs.Int("port", 0, "port")
+	fs.String("name", "", "name")
+	runDecodeTests(t, tests, func(data []byte) ([]entry, error) {
+		path := filepath.Join(dir, ".env")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return fs.readDotEnv(path)
+	})
+}
+
+// changeConcurrently calls read repeatedly while the flag "level" of fs
+// is changed at run time, for detecting races with the race detector.
+func changeConcurrently(t *testing.T, fs *FlagSet, read func()) {
+	t.Helper()
+	started, stop, done := make(chan bool), make(chan bool), make(chan bool)
+	go func() {
+		defer close(done)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				fs.setRuntime("level", fmt.Sprint(i))
+			}
+			if i == 0 {
+				close(started)
+			}
+		}
+	}()
+	<-started
+	for i := 0; i < 50; i++ {
+		read()
+	}
+	close(stop)
+	<-done
+}
+
+func newMutableSet(t *testing.T) *FlagSet {
+	fs := newTestSet()
+	fs.String("level", "info", "log level")
+	fs.MarkRuntimeMutable("level")
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	return fs
+}
+
+func TestHandlerConcurrentChanges(t *testing.T) {
+	fs := newMutableSet(t)
+	for _, target := range []string{"/?format=json", "/"} {
+		changeConcurrently(t, fs, func() {
+			rec := httptest.NewRecorder()
+			fs.Handler().ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("GET %s: status %d", target, rec.Code)
+			}
+		})
+	}
+}
+
+func TestPublishExpvarConcurrentChanges(t *testing.T) {
+	fs := newMutableSet(t)
+	name := expvarName(t)
+	fs.PublishExpvar(name)
+	v := expvar.Get(name)
+	changeConcurrently(t, fs, func() { _ = v.String() })
+}
+
+func TestAllSettingsConcurrentChanges(t *testing.T) {
+	fs := newMutableSet(t)
+	changeConcurrently(t, fs, func() { fs.AllSettings() })
+}
+
+func TestSetProgNameBadName(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "bad program name \"a") {
+			t.Errorf("recovered %v, want panic naming the program", r)
+		}
+	}()
+	newTestSet().SetProgName("a" + string(filepath.Separator) + "b")
+}
+
+func TestEdit(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		edit   func(ed *Editor) *Editor
+		want   string
+	}{
+		{"set in place", "# c\nport=80\nname=x\n",
+			func(ed *Editor) *Editor { return ed.Set("port", "90") },
+			"# c\nport=90\nname=x\n"},
+		{"add before section", "name=x\n\n[profile dev]\nport=1\n",
+			func(ed *Editor) *Editor { return ed.Set("port", "90") },
+			"name=x\nport=90\n\n[profile dev]\nport=1\n"},
+		{"remove duplicates", "port=80\nport=81\n",
+			func(ed *Editor) *Editor { return ed.Set("port", "90") },
+			"port=90\n"},
+		{"prefix section", "[server]\nhost=a\n",
+			func(ed *Editor) *Editor { return ed.Set("server.host", "b") },
+			"[server]\nhost=b\n"},
+		{"continuation", "name=a,\\\n  b\nport=1\n",
+			func(ed *Editor) *Editor { return ed.Set("name", "c") },
+			"name=c\nport=1\n"},
+		{"negated", "no-debug\nport=1\n",
+			func(ed *Editor) *Editor { return ed.Set("debug", "true") },
+			"debug=true\nport=1\n"},
+		{"negated after entry", "debug=false\nno-debug\n",
+			func(ed *Editor) *Editor { return ed.Set("debug", "true") },
+			"debug=true\n"},
+		{"migrated", "old-port=80\n",
+			func(ed *Editor) *Editor { return ed.Set("port", "90") },
+			"port=90\n"},
+		{"remove", "debug\nno-debug\nport=1\nold-port=2\n",
+			func(ed *Editor) *Editor { return ed.Remove("debug").Remove("port") },
+			""},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "test.conf")
+		if err := os.WriteFile(path, []byte(tt.config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		fs := newTestSet()
+		fs.Int("port", 0, "port")
+		fs.String("name", "", "name")
+		fs.Bool("debug", false, "debug")
+		fs.String("server.host", "", "host")
+		fs.MigrateKey("old-port", "port")
+		if err := tt.edit(fs.Edit(path)).Save(); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, data, tt.want)
+		}
+	}
+
+	fs := newTestSet()
+	fs.Int("port", 0, "port")
+	if err := fs.Edit(filepath.Join(t.TempDir(), "test.conf")).Set("port", "x").Save(); err == nil {
+		t.Error("expected error for invalid value")
+	}
+}
+
+func TestSaveNegatedAndMigrated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.conf")
+	config := "no-debug\nold-port=80\nname=x\n"
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	newSet := func() *FlagSet {
+		fs := newTestSet()
+		fs.Bool("debug", false, "debug")
+		fs.Int("port", 0, "port")
+		fs.String("name", "", "name")
+		fs.MigrateKey("old-port", "port")
+		return fs
+	}
+	fs := newSet()
+	if err := fs.ParseFS(os.DirFS(filepath.Dir(path)), "test.conf"); err != nil {
+		t.Fatal(err)
+	}
+	fs.Set("debug", "true")
+	fs.Set("port", "90")
+	if err := fs.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "debug=true\nport=90\nname=x\n"; string(data) != want {
+		t.Errorf("saved file %q, want %q", data, want)
+	}
+	fs = newSet()
+	if err := fs.ParseFS(os.DirFS(filepath.Dir(path)), "test.conf"); err != nil {
+		t.Fatal(err)
+	}
+	if v := fs.Lookup("debug").Value.String(); v != "true" {
+		t.Errorf("debug = %s after round trip, want true", v)
+	}
+
+	// Entries at the default value are removed.
+	fs.Set("debug", "false")
+	if err := fs.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "port=90\nname=x\n" {
+		t.Errorf("saved file %q after reset to default", data)
+	}
+}
+
+func TestDocConfigFilesNotExpanded(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	fs := newTestSet()
+	fs.SetProgName("mycmd")
+	fs.String("name", "", "name")
+	writers := map[string]func(io.Writer) error{
+		"man":      fs.WriteManPage,
+		"markdown": fs.WriteMarkdown,
+	}
+	for name, write := range writers {
+		var b bytes.Buffer
+		if err := write(&b); err != nil {
+			t.Fatal(err)
+		}
+		out := b.String()
+		for _, want := range []string{"{sysconfdir}/mycmd", "~/.mycmd", "{configdir}/mycmd/config"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s: output doesn't contain %q:\n%s", name, want, out)
+			}
+		}
+		if strings.Contains(out, home) {
+			t.Errorf("%s: output contains home directory %q:\n%s", name, home, out)
+		}
+	}
+}
+
+func TestMarkSensitive(t *testing.T) {
+	fs := newTestSet()
+	fs.String("password", "", "password")
+	fs.String("user", "", "user")
+	fs.Alias("password", "pw")
+	fs.MarkSensitive("pw")
+	for _, name := range []string{"password", "pw"} {
+		if !fs.IsSensitive(name) {
+			t.Errorf("IsSensitive(%q) = false, want true", name)
+		}
+	}
+	if fs.IsSensitive("user") {
+		t.Errorf("IsSensitive(%q) = true, want false", "user")
+	}
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "undefined flag pasword") {
+			t.Errorf("recovered %v, want panic naming the undefined flag", r)
+		}
+	}()
+	fs.MarkSensitive("pasword")
+}
+
+func TestProbeListen(t *testing.T) {
+	tests := []struct {
+		addr string
+		ok   bool
+	}{
+		{"127.0.0.1:0", true},
+		{"unix://" + filepath.Join(t.TempDir(), "s.sock"), true},
+		{"unix://" + filepath.Join(t.TempDir(), "missing", "s.sock"), false},
+		{"localhost", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		fs := newTestSet()
+		fs.String("listen", tt.addr, "listen address")
+		fs.AddProbe("listen", ProbeListen)
+		r := fs.RunProbes()
+		if len(r) != 1 {
+			t.Fatalf("%q: got %d results, want 1", tt.addr, len(r))
+		}
+		if ok := r[0].Err == nil; ok != tt.ok {
+			t.Errorf("%q: probe error %v, want ok=%v", tt.addr, r[0].Err, tt.ok)
+		}
+	}
+}
+
+func TestAddProbeUndefined(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "undefined flag lisen") {
+			t.Errorf("recovered %v, want panic naming the undefined flag", r)
+		}
+	}()
+	newTestSet().AddProbe("lisen", ProbeListen)
+}
+
+func TestWriteJSONSchema(t *testing.T) {
+	fs := newTestSet()
+	fs.SetProgName("mycmd")
+	fs.Int("port", 8080, "listen `port`")
+	fs.Bool("verbose", false, "verbose output")
+	fs.String("password", "secret", "password")
+	fs.Int("db.max-conns", 10, "maximum connections")
+	fs.IntSlice("ports", []int{80, 443}, "ports")
+	fs.DurationSlice("retry", nil, "retry schedule")
+	fs.String("internal", "", "internal")
+	fs.MarkSensitive("password")
+	fs.Hide("internal")
+	var b bytes.Buffer
+	if err := fs.WriteJSONSchema(&b); err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &schema); err != nil {
+		t.Fatal(err)
+	}
+	property := func(path ...string) map[string]interface{} {
+		obj := schema
+		for _, name := range path {
+			obj, _ = obj["properties"].(map[string]interface{})[name].(map[string]interface{})
+			if obj == nil {
+				return nil
+			}
+		}
+		return obj
+	}
+	tests := []struct {
+		path    []string
+		keyword string
+		want    string // JSON, or empty for missing keywords
+	}{
+		{[]string{"port"}, "type", `"integer"`},
+		{[]string{"port"}, "default", `8080`},
+		{[]string{"port"}, "description", `"listen port"`},
+		{[]string{"verbose"}, "type", `"boolean"`},
+		{[]string{"password"}, "writeOnly", `true`},
+		{[]string{"password"}, "default", ``},
+		{[]string{"db"}, "type", `"object"`},
+		{[]string{"db", "max-conns"}, "default", `10`},
+		{[]string{"ports"}, "type", `["string","array"]`},
+		{[]string{"ports"}, "items", `{"type":"integer"}`},
+		{[]string{"ports"}, "default", `"80,443"`},
+		{[]string{"retry"}, "items", `{"type":"string"}`},
+	}
+	for _, tt := range tests {
+		p := property(tt.path...)
+		if p == nil {
+			t.Errorf("%v: missing property", tt.path)
+			continue
+		}
+		got := ""
+		if v, ok := p[tt.keyword]; ok {
+			data, _ := json.Marshal(v)
+			got = string(data)
+		}
+		if got != tt.want {
+			t.Errorf("%v: %s = %s, want %s", tt.path, tt.keyword, got, tt.want)
+		}
+	}
+	if property("internal") != nil {
+		t.Errorf("hidden flag is described")
+	}
+	if schema["title"] != "mycmd" {
+		t.Errorf("title = %v, want mycmd", schema["title"])
+	}
+}
+
+func TestWriteJSONSchemaTableConflict(t *testing.T) {
+	fs := newTestSet()
+	fs.String("db", "", "database")
+	fs.Int("db.max-conns", 10, "maximum connections")
+	err := fs.WriteJSONSchema(io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "-db.max-conns") {
+		t.Errorf("got error %v, want conflict of -db and -db.max-conns", err)
+	}
+	fs.Hide("db")
+	if err := fs.WriteJSONSchema(io.Discard); err != nil {
+		t.Errorf("with hidden -db: %v", err)
+	}
+}
+
+func TestWriteEnvQuoting(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"plain-value_1.0", "plain-value_1.0"},
+		{"", `""`},
+		{"two words", `"two words"`},
+		{`it's "quoted"`, `"it's \"quoted\""`},
+		{`C:\data`, `"C:\\data"`},
+		{"$HOME `id`", "\"\\$HOME \\`id\\`\""},
+	}
+	for _, tt := range tests {
+		fs := newTestSet()
+		fs.String("value", tt.value, "value")
+		var b strings.Builder
+		if err := fs.WriteEnv(&b, "APP_"); err != nil {
+			t.Fatal(err)
+		}
+		if want := "APP_VALUE=" + tt.want + "\n"; b.String() != want {
+			t.Errorf("WriteEnv wrote %q, want %q", b.String(), want)
+		}
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	config := "port=80\n[profile dev]\nport=8080\ndebug\n[profile prod]\nport=443\n"
+	tests := []struct {
+		name  string
+		setup func(fs *FlagSet)
+		args  []string
+		port  int
+		debug bool
+	}{
+		{"no profile", nil, nil, 80, false},
+		{"SetProfile", func(fs *FlagSet) { fs.SetProfile("dev") }, nil, 8080, true},
+		{"profile flag", func(fs *FlagSet) { fs.EnableProfileFlag("profile") }, []string{"-profile", "prod"}, 443, false},
+		{"flag overrides SetProfile", func(fs *FlagSet) {
+			fs.SetProfile("dev")
+			fs.EnableProfileFlag("profile")
+		}, []string{"-profile=prod"}, 443, false},
+		{"unknown profile", func(fs *FlagSet) { fs.SetProfile("test") }, nil, 80, false},
+	}
+	for _, tt := range tests {
+		fs := newTestSet()
+		port := fs.Int("port", 0, "port")
+		debug := fs.Bool("debug", false, "debug")
+		if tt.setup != nil {
+			tt.setup(fs)
+		}
+		fs.SetDefaultConfig([]byte(config))
+		if err := fs.Parse(tt.args); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if *port != tt.port || *debug != tt.debug {
+			t.Errorf("%s: port=%d debug=%v, want port=%d debug=%v", tt.name, *port, *debug, tt.port, tt.debug)
+		}
+	}
+}
+
+func TestProfileEnv(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MYCMD_PROFILE", "dev")
+	fs := newTestSet()
+	fs.SetProgName("mycmd")
+	fs.SetSysConfDir(t.TempDir())
+	fs.SetProfile("prod")
+	if got := fs.Profile(); got != "dev" {
+		t.Errorf("Profile() = %q, want %q", got, "dev")
+	}
+}
+
+func TestBadSections(t *testing.T) {
+	for _, config := range []string{
+		"[profile]\nport=1\n",
+		"[unknown section]\nport=1\n",
+		"[profile dev\nport=1\n",
+	} {
+		fs := newTestSet()
+		fs.Int("port", 0, "port")
+		if err := fs.ParseReader(strings.NewReader(config)); err == nil {
+			t.Errorf("%q: expected error", config)
+		}
+	}
+}
+
+func TestHostSections(t *testing.T) {
+	host, err := os.Hostname()
+	if err != nil {
+		t.Skip(err)
+	}
+	short, _, _ := strings.Cut(host, ".")
+	tests := []struct {
+		name   string
+		config string
+		port   int // 0 if an error is expected
+	}{
+		{"full name", "port=80\n[host " + host + "]\nport=1\n", 1},
+		{"short name", "port=80\n[host " + short + "]\nport=1\n", 1},
+		{"upper case", "port=80\n[host " + strings.ToUpper(host) + "]\nport=1\n", 1},
+		{"glob", "port=80\n[host *]\nport=1\n", 1},
+		{"other host", "port=80\n[host no-such-host.invalid]\nport=1\n", 80},
+		{"host overrides profile", "[host *]\nport=1\n[profile dev]\nport=2\n", 1},
+		{"missing name", "[host]\nport=1\n", 0},
+		{"bad pattern", "[host [a]\nport=1\n", 0},
+	}
+	for _, tt := range tests {
+		fs := newTestSet()
+		port := fs.Int("port", 0, "port")
+		fs.SetProfile("dev")
+		err := fs.ParseReader(strings.NewReader(tt.config))
+		if tt.port == 0 {
+			if err == nil {
+				t.Errorf("%s: expected error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if *port != tt.port {
+			t.Errorf("%s: port=%d, want %d", tt.name, *port, tt.port)
+		}
+	}
+}
+
+func TestCommands(t *testing.T) {
+	config := "verbose\nport=80\n[command serve]\nport=8080\n[command other]\nport=1\n"
+	tests := []struct {
+		name     string
+		args     []string
+		selected string
+		port     int
+		rest     []string
+	}{
+		{"no command", nil, "", 0, nil},
+		{"command", []string{"serve"}, "serve", 8080, nil},
+		{"command flags", []string{"serve", "-port=9", "x"}, "serve", 9, []string{"x"}},
+		{"unknown command", []string{"run", "-port=9"}, "", 0, []string{"run", "-port=9"}},
+	}
+	for _, tt := range tests {
+		fs := newTestSet()
+		verbose := fs.Bool("verbose", false, "verbose output")
+		fs.Int("port", 0, "port")
+		serve := fs.Command("serve")
+		port := serve.Int("port", 0, "port")
+		fs.Command("other").Int("port", 0, "port")
+		fs.SetDefaultConfig([]byte(config))
+		if err := fs.Parse(tt.args); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var selected string
+		if c := fs.Selected(); c != nil {
+			selected = c.CommandName()
+		}
+		if selected != tt.selected {
+			t.Errorf("%s: selected command %q, want %q", tt.name, selected, tt.selected)
+		}
+		if !*verbose || *port != tt.port {
+			t.Errorf("%s: verbose=%v port=%d, want verbose=true port=%d", tt.name, *verbose, *port, tt.port)
+		}
+		args := fs.Args()
+		if c := fs.Selected(); c != nil {
+			args = c.Args()
+		}
+		if strings.Join(args, " ") != strings.Join(tt.rest, " ") {
+			t.Errorf("%s: arguments %q, want %q", tt.name, args, tt.rest)
+		}
+	}
+}
+
+func TestCommandSettings(t *testing.T) {
+	fs := newTestSet()
+	fs.Command("serve")
+	fs.Command("init")
+	if c := fs.Command("serve"); c != fs.Command("serve") || c.CommandName() != "serve" {
+		t.Errorf("Command returned a different set or name %q", c.CommandName())
+	}
+	if got := strings.Join(fs.Commands(), " "); got != "init serve" {
+		t.Errorf("Commands() = %q, want %q", got, "init serve")
+	}
+	fs.SetProfile("dev")
+	if got := fs.Command("serve").Profile(); got != "dev" {
+		t.Errorf("profile of command = %q, want %q", got, "dev")
+	}
+	fs.Int("port", 0, "port")
+	if err := fs.ParseReader(strings.NewReader("[command]\nport=1\n")); err == nil {
+		t.Errorf("expected error for command section without name")
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		args   []string
+		conns  int
+		host   string
+	}{
+		{"defaults", "", nil, 10, "localhost"},
+		{"dotted keys", "db.max-conns=20\ndb.host=db1\n", nil, 20, "db1"},
+		{"section", "[db]\nmax-conns=30\n", nil, 30, "localhost"},
+		{"command line", "[db]\nmax-conns=30\n", []string{"-db.max-conns=40"}, 40, "localhost"},
+	}
+	for _, tt := range tests {
+		fs := newTestSet()
+		ns := fs.NewNamespace("db.")
+		conns := ns.Int("max-conns", 10, "maximum number of connections")
+		var host string
+		ns.StringVar(&host, "host", "localhost", "database host")
+		fs.SetDefaultConfig([]byte(tt.config))
+		if err := fs.Parse(tt.args); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if *conns != tt.conns || host != tt.host {
+			t.Errorf("%s: max-conns=%d host=%q, want %d, %q", tt.name, *conns, host, tt.conns, tt.host)
+		}
+		if ns.Name("host") != "db.host" || ns.Lookup("max-conns") != fs.Lookup("db.max-conns") {
+			t.Errorf("%s: flags aren't named with the prefix", tt.name)
+		}
+	}
+}
+
+func TestNamespaceBadName(t *testing.T) {
+	for _, name := range []string{"", ".", "a b", "a]", "a=b"} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("%q: expected panic", name)
+				}
+			}()
+			newTestSet().NewNamespace(name)
+		}()
+	}
+}
+
+type RegisterEmbedded struct {
+	Verbose bool
+}
+
+type registerConfig struct {
+	Listen  string        `conflag:"listen,listen address,:8080"`
+	Timeout time.Duration `conflag:",request timeout"`
+	Debug   bool          `conflag:"-"`
+	Tags    string        `conflag:",tags,a,b"`
+	Addr    netip.Addr    `conflag:"addr,address,127.0.0.1"`
+	secret  string
+	DB      struct {
+		MaxConns int `conflag:"max-conns,maximum number of connections,10"`
+	} `conflag:"db"`
+	Cache *struct {
+		SizeMB uint64
+	}
+	RegisterEmbedded
+}
+
+func TestRegister(t *testing.T) {
+	fs := newTestSet()
+	cfg := registerConfig{Timeout: 5 * time.Second}
+	fs.Register(&cfg)
+	defs := []struct {
+		name, usage, def string
+	}{
+		{"listen", "listen address", ":8080"},
+		{"timeout", "request timeout", "5s"},
+		{"tags", "tags", "a,b"},
+		{"addr", "address", "127.0.0.1"},
+		{"db.max-conns", "maximum number of connections", "10"},
+		{"cache.size-mb", "", "0"},
+		{"verbose", "", "false"},
+	}
+	for _, d := range defs {
+		f := fs.Lookup(d.name)
+		if f == nil {
+			t.Errorf("flag -%s is not defined", d.name)
+			continue
+		}
+		if f.Usage != d.usage || f.DefValue != d.def {
+			t.Errorf("-%s: usage %q, default %q, want %q, %q", d.name, f.Usage, f.DefValue, d.usage, d.def)
+		}
+	}
+	n := 0
+	fs.VisitAll(func(*flag.Flag) { n++ })
+	if n != len(defs) {
+		t.Errorf("defined %d flags, want %d", n, len(defs))
+	}
+	fs.SetDefaultConfig([]byte("[db]\nmax-conns=20\n"))
+	if err := fs.Parse([]string{"-listen=:9090", "-verbose", "-addr=::1", "-cache.size-mb=64"}); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Listen != ":9090" || !cfg.Verbose || cfg.Addr != netip.IPv6Loopback() ||
+		cfg.DB.MaxConns != 20 || cfg.Cache.SizeMB != 64 || cfg.Timeout != 5*time.Second {
+		t.Errorf("fields aren't bound to flags: %+v", cfg)
+	}
+}
+
+func TestRegisterPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+	}{
+		{"not pointer", registerConfig{}},
+		{"nil pointer", (*registerConfig)(nil)},
+		{"pointer to int", new(int)},
+		{"unsupported type", &struct{ C chan int }{}},
+		{"bad default", &struct {
+			N int `conflag:"n,number,ten"`
+		}{}},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if r := recover(); r == nil || !strings.HasPrefix(fmt.Sprint(r), "conflag: ") {
+					t.Errorf("%s: recovered %v, want conflag panic", tt.name, r)
+				}
+			}()
+			newTestSet().Register(tt.v)
+		}()
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	fs := newTestSet()
+	fs.Int("port", 80, "port")
+	fs.Int("count", 3, "count")
+	fs.Duration("timeout", time.Second, "timeout")
+	fs.String("addr", "::1", "address")
+	fs.Int("db.max-conns", 10, "maximum number of connections")
+	if err := fs.Parse([]string{"-port=8080", "-db.max-conns=20"}); err != nil {
+		t.Fatal(err)
+	}
+	var cfg struct {
+		Port    int
+		Count   int64 // converted from the string value
+		Timeout time.Duration
+		Addr    netip.Addr
+		Missing string
+		DB      struct {
+			MaxConns int
+		} `conflag:"db"`
+	}
+	cfg.Missing = "unchanged"
+	if err := fs.Unmarshal(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 8080 || cfg.Count != 3 || cfg.Timeout != time.Second ||
+		cfg.Addr != netip.IPv6Loopback() || cfg.Missing != "unchanged" || cfg.DB.MaxConns != 20 {
+		t.Errorf("Unmarshal stored %+v", cfg)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+	}{
+		{"not pointer", struct{ Port int }{}},
+		{"pointer to int", new(int)},
+		{"unsupported type", &struct{ Port chan int }{}},
+		{"bad value", &struct{ Name int }{}},
+	}
+	for _, tt := range tests {
+		fs := newTestSet()
+		fs.Int("port", 80, "port")
+		fs.String("name", "abc", "name")
+		if err := fs.Unmarshal(tt.v); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []decodeTest{
+		{"scalars", `{"port": 80, "verbose": true, "name": "x", "ratio": 0.5}`,
+			[]string{"name=x", "port=80", "ratio=0.5", "verbose=true"}},
+		{"numbers as written", `{"big": 12345678901234567890, "exp": 1e3}`, []string{"big=12345678901234567890", "exp=1e3"}},
+		{"nested", `{"db": {"host": "x", "pool": {"size": 5}}}`, []string{"db.host=x", "db.pool.size=5"}},
+		{"array", `{"host": ["a", "b"]}`, []string{"host=a", "host=b"}},
+		{"null", `{"port": null}`, []string{}},
+		{"empty", ``, nil},
+		{"bom", "\uFEFF{\"port\": 80}", []string{"port=80"}},
+		{"not object", `[1, 2]`, nil},
+		{"nested array", `{"a": [[1]]}`, nil},
+		{"map in array", `{"a": [{"b": 1}]}`, nil},
+		{"syntax error", "{\n\"port\": 80,\n}", nil},
+		{"trailing data", `{"port": 80} {}`, nil},
+	}
+	runDecodeTests(t, tests, func(data []byte) ([]entry, error) {
+		return decodeJSON("test.json", data)
+	})
+}
+
+func TestParseReaderDetectsJSON(t *testing.T) {
+	fs := newTestSet()
+	port := fs.Int("port", 0, "port")
+	host := fs.String("db.host", "", "database host")
+	if err := fs.ParseReader(strings.NewReader(`{"port": 80, "db": {"host": "x"}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if *port != 80 || *host != "x" {
+		t.Errorf("port=%d db.host=%q", *port, *host)
+	}
+}
+
+// newFileTestSet returns a test set for the program "mycmd" that reads
+// configuration files from temporary global and home directories, which
+// it also returns, with environment variables that select other files
+// cleared.
+func newFileTestSet(t *testing.T) (fs *FlagSet, etc, home string) {
+	etc, home = t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	SetHomeDir(home)
+	t.Cleanup(func() { SetHomeDir("") })
+	for _, suffix := range []string{"CONFIG", "CONFIG_PATH", "SKIP_CONFIG", "NO_CONFIG", "PROFILE", "SYSCONFDIR"} {
+		t.Setenv(EnvName("mycmd", suffix), "")
+	}
+	fs = newTestSet()
+	fs.SetProgName("mycmd")
+	fs.SetSysConfDir(etc)
+	return fs, etc, home
+}
+
+// writeFile writes the file, failing the test on errors, and returns its
+// path.
+func writeFile(t *testing.T, path, content string) string {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPrecedence(t *testing.T) {
+	tests := []struct {
+		name   string
+		layers []Layer // nil for the default order
+		args   []string
+		port   int
+		source string // "global", "user", or the source of the value
+	}{
+		{"default order", nil, []string{"-port=5"}, 5, SourceCommandLine},
+		{"default order without arguments", nil, nil, 3, "user"},
+		{"environment", []Layer{LayerDefault, LayerGlobal, LayerUser, LayerEnv, LayerCommandLine}, nil, 4, "$MYCMD_PORT"},
+		{"files override command line", []Layer{LayerCommandLine, LayerDefault, LayerGlobal, LayerUser}, []string{"-port=5"}, 3, "user"},
+		{"only global", []Layer{LayerGlobal, LayerCommandLine}, nil, 2, "global"},
+		{"environment first", []Layer{LayerEnv, LayerGlobal, LayerCommandLine}, nil, 2, "global"},
+		{"only default", []Layer{LayerDefault, LayerCommandLine}, nil, 1, defaultConfigName},
+		{"only command line", []Layer{LayerCommandLine}, nil, 0, SourceDefault},
+	}
+	for _, tt := range tests {
+		fs, etc, home := newFileTestSet(t)
+		files := map[string]string{
+			"global": writeFile(t, filepath.Join(etc, "mycmd"), "port=2\n"),
+			"user":   writeFile(t, filepath.Join(home, ".mycmd"), "port=3\n"),
+		}
+		t.Setenv("MYCMD_PORT", "4")
+		port := fs.Int("port", 0, "port")
+		fs.SetDefaultConfig([]byte("port=1\n"))
+		if tt.layers != nil {
+			fs.SetPrecedence(tt.layers...)
+		}
+		if err := fs.Parse(tt.args); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		source := tt.source
+		if f, ok := files[source]; ok {
+			source = f
+		}
+		if *port != tt.port || fs.Source("port") != source {
+			t.Errorf("%s: port=%d from %q, want %d from %q", tt.name, *port, fs.Source("port"), tt.port, source)
+		}
+	}
+}
+
+func TestSetPrecedencePanics(t *testing.T) {
+	tests := [][]Layer{
+		{LayerGlobal, LayerUser},
+		{LayerUser, LayerCommandLine, LayerUser},
+		{LayerCommandLine, Layer(-1)},
+		{LayerCommandLine, LayerDefault + 1},
+	}
+	for _, layers := range tests {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("%v: expected panic", layers)
+				}
+			}()
+			newTestSet().SetPrecedence(layers...)
+		}()
+	}
+	if got := LayerCommandLine.String(); got != "command line" {
+		t.Errorf("LayerCommandLine.String() = %q", got)
+	}
+	if got := Layer(42).String(); got != "Layer(42)" {
+		t.Errorf("Layer(42).String() = %q", got)
+	}
+}
+
+func TestDuplicateKeyPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   DuplicateKeyPolicy
+		config   string
+		port     int // 0 if an error is expected
+		warnings int
+	}{
+		{"last wins", DuplicateKeyLastWins, "port=1\nport=2\n", 2, 0},
+		{"warn", DuplicateKeyWarn, "port=1\nport=2\n", 2, 1},
+		{"first wins", DuplicateKeyFirstWins, "port=1\nport=2\nport=3\n", 1, 2},
+		{"error", DuplicateKeyError, "port=1\nport=2\n", 0, 0},
+		{"alias", DuplicateKeyError, "port=1\np=2\n", 0, 0},
+		{"normalized", DuplicateKeyError, "port=1\nPORT=2\n", 0, 0},
+		{"different sections", DuplicateKeyError, "port=1\n[profile dev]\nport=2\n", 2, 0},
+		{"array elements", DuplicateKeyError, "{\"ports\": [80, 443], \"port\": 1}", 1, 0},
+		{"nested and dotted", DuplicateKeyError, "{\"db\": {\"port\": 1}, \"db.port\": 2}", 0, 0},
+	}
+	for _, tt := range tests {
+		fs := newTestSet()
+		port := fs.Int("port", 0, "port")
+		fs.IntSlice("ports", nil, "ports")
+		fs.Int("db.port", 0, "database port")
+		fs.Alias("port", "p")
+		fs.SetNormalizeFunc(NormalizeName)
+		fs.SetProfile("dev")
+		fs.SetDuplicateKeyPolicy(tt.policy)
+		warnings := 0
+		fs.SetLogger(func(level, msg string) {
+			if level == LevelWarn && strings.Contains(msg, "duplicate key") {
+				warnings++
+			}
+		})
+		err := fs.ParseReader(strings.NewReader(tt.config))
+		if tt.port == 0 {
+			if err == nil || !strings.Contains(err.Error(), "duplicate key") {
+				t.Errorf("%s: got error %v, want duplicate key error", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if *port != tt.port || warnings != tt.warnings {
+			t.Errorf("%s: port=%d with %d warnings, want %d with %d", tt.name, *port, warnings, tt.port, tt.warnings)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		values   map[string]string
+		sources  map[string]string
+		rest     []string
+		warnings int
+		command  string // value of -port of the command, if selected
+		err      bool
+	}{
+		{"defaults", nil,
+			map[string]string{"port": "80", "password": mask, "timeout": "1s"},
+			map[string]string{"port": SourceDefault, "password": SourceDefault},
+			[]string{}, 0, "", false},
+		{"arguments", []string{"-port=8080", "-password=hunter2", "x"},
+			map[string]string{"port": "8080", "password": mask},
+			map[string]string{"port": SourceCommandLine, "timeout": SourceDefault},
+			[]string{"x"}, 0, "", false},
+		{"default config", []string{"-timeout=5s"},
+			map[string]string{"name": "conf", "timeout": "5s"},
+			map[string]string{"name": defaultConfigName, "timeout": SourceCommandLine},
+			[]string{}, 0, "", false},
+		{"deprecated", []string{"-old-port=90"},
+			map[string]string{"port": "90"}, nil, []string{}, 1, "", false},
+		{"command", []string{"serve", "-port=1"}, nil, nil, []string{"serve", "-port=1"}, 0, "1", false},
+		{"bad value", []string{"-port=x"}, nil, nil, nil, 0, "", true},
+		{"unknown flag", []string{"-nope"}, nil, nil, nil, 0, "", true},
+	}
+	for _, tt := range tests {
+		fs := New("test", flag.ExitOnError)
+		fs.SetOutput(io.Discard)
+		port := fs.Int("port", 80, "port")
+		fs.String("password", "secret", "password")
+		fs.Duration("timeout", time.Second, "timeout")
+		name := fs.String("name", "", "name")
+		fs.MarkSensitive("password")
+		fs.DeprecateAlias("old-port", "port")
+		cmdPort := fs.Command("serve").Int("port", 0, "port")
+		fs.SetDefaultConfig([]byte("name=conf\n"))
+		r, err := fs.DryRun(tt.args)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.err)
+			continue
+		}
+		if *port != 80 || *name != "" || *cmdPort != 0 || fs.Source("port") != SourceDefault || fs.Parsed() {
+			t.Errorf("%s: flag set was changed", tt.name)
+		}
+		if tt.err {
+			continue
+		}
+		for k, want := range tt.values {
+			if got := r.Values[k]; got != want {
+				t.Errorf("%s: value of -%s = %q, want %q", tt.name, k, got, want)
+			}
+		}
+		for k, want := range tt.sources {
+			if got := r.Sources[k]; got != want {
+				t.Errorf("%s: source of -%s = %q, want %q", tt.name, k, got, want)
+			}
+		}
+		if strings.Join(r.Args, " ") != strings.Join(tt.rest, " ") || len(r.Warnings) != tt.warnings {
+			t.Errorf("%s: args %q, warnings %q", tt.name, r.Args, r.Warnings)
+		}
+		if got := r.Command != nil; got != (tt.command != "") {
+			t.Errorf("%s: command result %v", tt.name, r.Command)
+		} else if got && r.Command.Values["port"] != tt.command {
+			t.Errorf("%s: command -port = %q, want %q", tt.name, r.Command.Values["port"], tt.command)
+		}
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	fs := newTestSet()
+	port := fs.Int("port", 80, "port")
+	name := fs.String("name", "", "name")
+	ports := fs.IntSlice("ports", []int{1}, "ports")
+	cmdPort := fs.Command("serve").Int("port", 0, "port")
+	if err := fs.Parse([]string{"-port=8080", "-ports=2,3", "serve", "-port=1"}); err != nil {
+		t.Fatal(err)
+	}
+	st := fs.Snapshot()
+	changes := []func() error{
+		func() error { return fs.Set("name", "x") },
+		func() error { return fs.Set("ports", "4") },
+		func() error { return fs.Parse([]string{"-port=9", "serve", "-port=2"}) },
+	}
+	for _, change := range changes {
+		if err := change(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fs.Restore(st); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, got, want string
+	}{
+		{"port", fmt.Sprint(*port), "8080"},
+		{"name", *name, ""},
+		{"ports", fmt.Sprint(*ports), "[2 3]"},
+		{"command port", fmt.Sprint(*cmdPort), "1"},
+		{"source of port", fs.Source("port"), SourceCommandLine},
+		{"source of name", fs.Source("name"), SourceDefault},
+		{"source of command port", fs.Command("serve").Source("port"), SourceCommandLine},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q after Restore, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+	// The state can be restored more than once.
+	*port = 1
+	if err := fs.Restore(st); err != nil || *port != 8080 {
+		t.Errorf("second Restore: port=%d, error %v", *port, err)
+	}
+}
+
+func TestClone(t *testing.T) {
+	tmpl := newTestSet()
+	port := tmpl.Int("port", 80, "port")
+	tmpl.IntSlice("ports", []int{1}, "ports")
+	tmpl.String("password", "", "password")
+	tmpl.Alias("port", "p")
+	tmpl.MarkSensitive("password")
+	tmpl.Command("serve").Duration("timeout", time.Second, "timeout")
+	tmpl.SetDefaultConfig([]byte("port=81\n[profile dev]\nport=82\n"))
+
+	c := tmpl.Clone()
+	c.SetProfile("dev")
+	c.Alias("port", "listen-port")
+	if err := c.Parse([]string{"-ports=2,3", "serve", "-timeout=5s"}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, got, want string
+	}{
+		{"port", c.Lookup("p").Value.String(), "82"},
+		{"ports", c.Lookup("ports").Value.String(), "2,3"},
+		{"default of ports", c.Lookup("ports").DefValue, "1"},
+		{"command timeout", c.Selected().Lookup("timeout").Value.String(), "5s"},
+		{"sensitive", fmt.Sprint(c.IsSensitive("password")), "true"},
+		{"template port", fmt.Sprint(*port), "80"},
+		{"template source", tmpl.Source("port"), SourceDefault},
+		{"template profile", tmpl.Profile(), ""},
+		{"template alias", fmt.Sprint(tmpl.Lookup("listen-port") != nil), "false"},
+		{"template command timeout", tmpl.Command("serve").Lookup("timeout").Value.String(), "1s"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+	if n, err := c.GetInt("port"); err != nil || n != 82 {
+		t.Errorf("GetInt(port) = %d, %v", n, err)
+	}
+
+	// Clones of the same template are independent.
+	c2 := tmpl.Clone()
+	if err := c2.Parse([]string{"-port=1"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Lookup("port").Value.String(); got != "82" {
+		t.Errorf("port of first clone = %q after parsing second clone", got)
+	}
+}
+
+func TestReload(t *testing.T) {
+	fs, _, home := newFileTestSet(t)
+	path := writeFile(t, filepath.Join(home, ".mycmd"), "port=1\nlevel=info\nports=1,2\nname=file\n")
+	port := fs.Int("port", 0, "port")
+	level := fs.String("level", "", "log level")
+	ports := fs.IntSlice("ports", nil, "ports")
+	name := fs.String("name", "", "name")
+	fs.MarkReloadable("level", "ports", "name")
+	var changed, warnings []string
+	fs.OnChange(func(name, oldValue, newValue string) {
+		changed = append(changed, name+":"+oldValue+"->"+newValue)
+	})
+	fs.SetLogger(func(level, msg string) {
+		if level == LevelWarn {
+			warnings = append(warnings, msg)
+		}
+	})
+	if err := fs.Parse([]string{"-name=arg"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		config   string
+		err      bool
+		level    string
+		ports    string
+		changed  []string
+		warnings int
+	}{
+		{"unchanged", "port=1\nlevel=info\nports=1,2\nname=file\n", false, "info", "[1 2]", nil, 0},
+		{"reloadable", "port=1\nlevel=debug\nports=3\n", false, "debug", "[3]",
+			[]string{"level:info->debug", "ports:1,2->3"}, 0},
+		{"not reloadable", "port=2\nlevel=debug\nports=3\n", false, "debug", "[3]", nil, 1},
+		{"error", "port=2\nlevel=warn\nports=x\n", true, "debug", "[3]", nil, 0},
+	}
+	for _, tt := range tests {
+		changed, warnings = nil, nil
+		writeFile(t, path, tt.config)
+		if err := fs.Reload(); (err != nil) != tt.err {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.err)
+		}
+		if *port != 1 || *name != "arg" || *level != tt.level || fmt.Sprint(*ports) != tt.ports {
+			t.Errorf("%s: port=%d name=%q level=%q ports=%v", tt.name, *port, *name, *level, *ports)
+		}
+		if strings.Join(changed, " ") != strings.Join(tt.changed, " ") || len(warnings) != tt.warnings {
+			t.Errorf("%s: changed %q, warnings %q", tt.name, changed, warnings)
+		}
+	}
+	if got := fs.Source("level"); got != path {
+		t.Errorf("source of -level = %q, want %q", got, path)
+	}
+}
+
+func TestMarkReloadableUndefined(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "undefined flag levl") {
+			t.Errorf("recovered %v, want panic naming the undefined flag", r)
+		}
+	}()
+	newTestSet().MarkReloadable("levl")
+}
+
+func TestPrefixSections(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string // values of -port, -server.port, -server.tls.cert, or empty for errors
+	}{
+		{"top level", "port=1\n", "1 0 "},
+		{"section", "[server]\nport=2\n", "0 2 "},
+		{"nested section", "[server.tls]\ncert=a.pem\n", "0 0 a.pem"},
+		{"dotted key in section", "[server]\ntls.cert=b.pem\n", "0 0 b.pem"},
+		{"spaces in header", "[ server ]\nport=2\n", "0 2 "},
+		{"back to profile section", "[server]\nport=2\n[profile dev]\nport=3\n", "3 2 "},
+		{"unknown key in section", "[server]\nhost=x\n", ""},
+	}
+	for _, tt := range tests {
+		fs := newTestSet()
+		port := fs.Int("port", 0, "port")
+		serverPort := fs.Int("server.port", 0, "server port")
+		cert := fs.String("server.tls.cert", "", "certificate")
+		fs.SetProfile("dev")
+		err := fs.ParseReader(strings.NewReader(tt.config))
+		if tt.want == "" {
+			if err == nil || !strings.Contains(err.Error(), "server.host") {
+				t.Errorf("%s: got error %v, want error naming the key", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := fmt.Sprintf("%d %d %s", *port, *serverPort, *cert); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSearchPaths(t *testing.T) {
+	tmpl, etc, home := newFileTestSet(t)
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(etc, "mycmd"), "port=1\nname=global\n")
+	writeFile(t, filepath.Join(home, ".mycmd"), "port=2\nname=user\n")
+	a := writeFile(t, filepath.Join(dir, "a"), "port=3\nname=a\n")
+	b := writeFile(t, filepath.Join(home, "b"), "port=4\n")
+	c := writeFile(t, filepath.Join(dir, "c.json"), `{"port": 5}`)
+	tests := []struct {
+		name  string
+		paths []string // nil to keep the default paths
+		env   string   // value of MYCMD_CONFIG_PATH
+		port  int
+		files []string
+	}{
+		{"default paths", nil, "", 2, []string{filepath.Join(etc, "mycmd"), filepath.Join(home, ".mycmd")}},
+		{"in order", []string{a, "~/b", filepath.Join(dir, "missing")}, "", 4, []string{a, b}},
+		{"reversed", []string{"~/b", a}, "", 3, []string{b, a}},
+		{"extension detected", []string{a, filepath.Join(dir, "c")}, "", 5, []string{a, c}},
+		{"disabled", []string{}, "", 0, nil},
+		{"environment", []string{a}, "~/b" + string(filepath.ListSeparator) + filepath.Join(dir, "c"), 5, []string{b, c}},
+	}
+	for _, tt := range tests {
+		t.Setenv("MYCMD_CONFIG_PATH", tt.env)
+		fs := tmpl.Clone()
+		port := fs.Int("port", 0, "port")
+		fs.String("name", "", "name")
+		if tt.paths != nil {
+			fs.SetSearchPaths(tt.paths...)
+		}
+		if err := fs.Parse(nil); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if files := fs.ConfigFilesUsed(); *port != tt.port || strings.Join(files, " ") != strings.Join(tt.files, " ") {
+			t.Errorf("%s: port=%d from %q, want %d from %q", tt.name, *port, files, tt.port, tt.files)
+		}
+	}
+}
+
+func TestAliases(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		args   []string
+		port   int
+		source string
+	}{
+		{"command line", "", []string{"-p=1"}, 1, SourceCommandLine},
+		{"long alias", "", []string{"--listen-port", "2"}, 2, SourceCommandLine},
+		{"config file", "p=3\n", nil, 3, defaultConfigName},
+		{"canonical overrides alias", "p=3\n", []string{"-port=4"}, 4, SourceCommandLine},
+	}
+	for _, tt := range tests {
+		fs := newTestSet()
+		port := fs.Int("port", 0, "listen `port`")
+		fs.Alias("port", "p")
+		fs.Alias("port", "listen-port")
+		fs.SetDefaultConfig([]byte(tt.config))
+		if err := fs.Parse(tt.args); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if *port != tt.port || fs.Source("p") != tt.source {
+			t.Errorf("%s: port=%d from %q, want %d from %q", tt.name, *port, fs.Source("p"), tt.port, tt.source)
+		}
+	}
+
+	fs := newTestSet()
+	fs.Int("port", 0, "listen `port`")
+	fs.Alias("port", "p")
+	if fs.Lookup("p") != fs.Lookup("port") {
+		t.Errorf("Lookup of alias returned a different flag")
+	}
+	if err := fs.Set("p", "5"); err != nil || fs.Lookup("port").Value.String() != "5" {
+		t.Errorf("Set of alias: %v", err)
+	}
+	var b bytes.Buffer
+	fs.SetOutput(&b)
+	fs.PrintDefaults()
+	if !strings.Contains(b.String(), "-port, -p port") {
+		t.Errorf("usage doesn't list the alias:\n%s", b.String())
+	}
+}
+
+func TestAliasPanics(t *testing.T) {
+	tests := []struct {
+		name, flag, alias string
+	}{
+		{"undefined flag", "nope", "n"},
+		{"alias is a flag", "port", "name"},
+		{"alias redefined", "name", "p"},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("%s: expected panic", tt.name)
+				}
+			}()
+			fs := newTestSet()
+			fs.Int("port", 0, "port")
+			fs.String("name", "", "name")
+			fs.Alias("port", "p")
+			fs.Alias(tt.flag, tt.alias)
+		}()
+	}
+}
+
+func TestDeprecate(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		args     []string
+		warnings []string
+	}{
+		{"not used", "", nil, nil},
+		{"deprecated flag", "", []string{"-verbose"},
+			[]string{"command line: flag -verbose is deprecated: use -log-level"}},
+		{"deprecated alias", "old-port=1\n", nil,
+			[]string{"<default config>: flag -old-port is deprecated: use -port instead"}},
+		{"warned once", "old-port=1\n", []string{"-old-port=2", "-verbose", "-verbose"}, []string{
+			"<default config>: flag -old-port is deprecated: use -port instead",
+			"command line: flag -verbose is deprecated: use -log-level",
+		}},
+	}
+	for _, tt := range tests {
+		fs := newTestSet()
+		fs.Bool("verbose", false, "verbose output")
+		fs.Int("port", 0, "port")
+		fs.Deprecate("verbose", "use -log-level")
+		fs.DeprecateAlias("old-port", "port")
+		var warnings []string
+		fs.SetLogger(func(level, msg string) {
+			if level == LevelWarn {
+				warnings = append(warnings, msg)
+			}
+		})
+		fs.SetDefaultConfig([]byte(tt.config))
+		if err := fs.Parse(tt.args); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if strings.Join(warnings, "\n") != strings.Join(tt.warnings, "\n") {
+			t.Errorf("%s: warnings %q, want %q", tt.name, warnings, tt.warnings)
+		}
+	}
+
+	fs := newTestSet()
+	fs.Int("port", 0, "port")
+	fs.DeprecateAlias("old-port", "port")
+	var b bytes.Buffer
+	fs.SetOutput(&b)
+	fs.PrintDefaults()
+	if strings.Contains(b.String(), "old-port") {
+		t.Errorf("usage lists the deprecated alias:\n%s", b.String())
+	}
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("Deprecate of undefined flag didn't panic")
+		}
+	}()
+	fs.Deprecate("nope", "")
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"port", "port"},
+		{"max_conns", "max-conns"},
+		{"MAX_CONNS", "max-conns"},
+		{"MaxConns", "max-conns"},
+		{"maxConns", "max-conns"},
+		{"HTTPPort", "http-port"},
+		{"SizeMB", "size-mb"},
+		{"Port2Go", "port2-go"},
+		{"db.MaxConns", "db.max-conns"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeName(tt.name); got != tt.want {
+			t.Errorf("NormalizeName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizedKeys(t *testing.T) {
+	tests := []struct {
+		name      string
+		normalize bool
+		config    string
+		conns     int // -1 if an error is expected
+	}{
+		{"exact key", false, "max-conns=1\n", 1},
+		{"not normalized", false, "max_conns=1\n", -1},
+		{"underscores", true, "max_conns=2\n", 2},
+		{"upper case", true, "MAX_CONNS=3\n", 3},
+		{"camel case", true, "maxConns=4\n", 4},
+		{"alias", true, "MC=5\n", 5},
+		{"section", true, "[db]\nmax_conns=6\n", 6},
+		{"unknown", true, "min_conns=7\n", -1},
+	}
+	for _, tt := range tests {
+		fs := newTestSet()
+		conns := fs.Int("max-conns", 0, "maximum connections")
+		fs.Alias("max-conns", "mc")
+		dbConns := fs.Int("db.max-conns", 0, "maximum database connections")
+		if tt.normalize {
+			fs.SetNormalizeFunc(NormalizeName)
+		}
+		err := fs.ParseReader(strings.NewReader(tt.config))
+		if tt.conns < 0 {
+			if err == nil {
+				t.Errorf("%s: expected error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if *conns+*dbConns != tt.conns {
+			t.Errorf("%s: max-conns=%d db.max-conns=%d, want %d", tt.name, *conns, *dbConns, tt.conns)
+		}
+	}
+}
+
+func TestFlagRefs(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		args   []string
+		logs   string // empty if an error is expected
+	}{
+		{"reference", "dir=/srv\nlogs=${flag:dir}/logs\n", nil, "/srv/logs"},
+		{"default value", "logs=${flag:dir}/logs\n", nil, "/var/logs"},
+		{"command line value", "logs=${flag:dir}/logs\n", []string{"-dir=/opt"}, "/opt/logs"},
+		{"overridden", "logs=${flag:dir}/logs\n", []string{"-logs=/tmp"}, "/tmp"},
+		{"chain", "logs=${flag:data}/logs\ndata=${flag:dir}/data\n", nil, "/var/data/logs"},
+		{"several", "logs=${flag:dir}:${flag:data}\ndata=x\n", nil, "/var:x"},
+		{"literal", "logs='${flag:dir}'\n", nil, "${flag:dir}"},
+		{"undefined", "logs=${flag:nope}\n", nil, ""},
+		{"cycle", "logs=${flag:data}\ndata=${flag:logs}\n", nil, ""},
+	}
+	for _, tt := range tests {
+		fs := newTestSet()
+		fs.String("dir", "/var", "directory")
+		fs.String("data", "", "data directory")
+		logs := fs.String("logs", "", "log directory")
+		fs.SetDefaultConfig([]byte(tt.config))
+		err := fs.Parse(tt.args)
+		if tt.logs == "" {
+			if err == nil {
+				t.Errorf("%s: expected error, got -logs=%q", tt.name, *logs)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if *logs != tt.logs {
+			t.Errorf("%s: -logs=%q, want %q", tt.name, *logs, tt.logs)
+		}
+	}
+}
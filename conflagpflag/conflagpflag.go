@@ -0,0 +1,85 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package conflagpflag adapts flag sets of github.com/spf13/pflag, which
+// are used by cobra, to conflag, so that programs with existing pflag
+// definitions can set flags from configuration files and the environment.
+//
+// After the pflag set is parsed, for example, in cobra's
+// PersistentPreRunE, wrap it and parse the configuration:
+//
+//	fs := conflagpflag.New("mycmd", cmd.Flags())
+//	fs.SetPrecedence(conflag.LayerGlobal, conflag.LayerUser, conflag.LayerEnv, conflag.LayerCommandLine)
+//	if err := conflagpflag.Parse(fs, cmd.Flags()); err != nil {
+//		return err
+//	}
+//
+// Flags given on the command line keep their values.
+package conflagpflag
+
+import (
+	"flag"
+
+	"github.com/dchest/conflag"
+	"github.com/spf13/pflag"
+)
+
+// New returns a conflag flag set with the given program name that has
+// the flags of the pflag set, sharing their values, usage strings and
+// default values. Hidden pflag flags are hidden, and deprecated ones are
+// deprecated with the same message. Shorthands are not defined. The set
+// returns errors from Parse and writes to the output of the pflag set.
+func New(progName string, pfs *pflag.FlagSet) *conflag.FlagSet {
+	fs := conflag.New(progName, flag.ContinueOnError)
+	fs.SetProgName(progName)
+	fs.SetOutput(pfs.Output())
+	pfs.VisitAll(func(f *pflag.Flag) {
+		fs.Var(value{f}, f.Name, f.Usage)
+		fs.Lookup(f.Name).DefValue = f.DefValue
+		if f.Hidden {
+			fs.Hide(f.Name)
+		}
+		if f.Deprecated != "" {
+			fs.Deprecate(f.Name, f.Deprecated)
+		}
+	})
+	return fs
+}
+
+// Parse sets the flags of the pflag set, which must have been parsed, from
+// configuration files and other layers of the flag set returned by New.
+// Values of flags given on the command line are not changed, and their
+// source reported by fs.Source is conflag.SourceCommandLine.
+func Parse(fs *conflag.FlagSet, pfs *pflag.FlagSet) error {
+	var args []string
+	pfs.Visit(func(f *pflag.Flag) {
+		// Setting a changed flag has no effect, but records the source.
+		args = append(args, "-"+f.Name+"="+f.Value.String())
+	})
+	return fs.Parse(args)
+}
+
+// value is the flag.Value of a pflag flag, which ignores values for flags
+// given on the command line.
+type value struct {
+	f *pflag.Flag
+}
+
+func (v value) String() string {
+	if v.f == nil {
+		return ""
+	}
+	return v.f.Value.String()
+}
+
+func (v value) Set(s string) error {
+	if v.f.Changed {
+		return nil
+	}
+	return v.f.Value.Set(s)
+}
+
+func (v value) IsBoolFlag() bool {
+	return v.f.NoOptDefVal == "true"
+}
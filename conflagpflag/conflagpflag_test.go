@@ -0,0 +1,53 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflagpflag
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/dchest/conflag"
+	"github.com/dchest/conflag/conflagtest"
+	"github.com/spf13/pflag"
+)
+
+func TestParse(t *testing.T) {
+	env := conflagtest.New(t)
+	env.WriteUser(".mycmd", "port=80\nname=file\nverbose\n")
+	env.NewFlagSet("mycmd") // clears MYCMD_CONFIG and similar variables
+
+	pfs := pflag.NewFlagSet("mycmd", pflag.ContinueOnError)
+	pfs.SetOutput(io.Discard)
+	port := pfs.IntP("port", "p", 8080, "port")
+	name := pfs.String("name", "default", "name")
+	verbose := pfs.BoolP("verbose", "v", false, "verbose output")
+	timeout := pfs.Duration("timeout", 0, "timeout")
+	if err := pfs.Parse([]string{"-p", "9090"}); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := New("mycmd", pfs)
+	fs.SetOutput(io.Discard)
+	fs.SetSysConfDir(env.Etc)
+	if err := Parse(fs, pfs); err != nil {
+		t.Fatal(err)
+	}
+	if *port != 9090 || *name != "file" || !*verbose || *timeout != 0 {
+		t.Errorf("port=%d name=%q verbose=%v timeout=%v", *port, *name, *verbose, *timeout)
+	}
+	for flag, want := range map[string]string{
+		"port":    conflag.SourceCommandLine,
+		"name":    filepath.Join(env.Home, ".mycmd"),
+		"timeout": conflag.SourceDefault,
+	} {
+		if got := fs.Source(flag); got != want {
+			t.Errorf("source of -%s = %q, want %q", flag, got, want)
+		}
+	}
+	if got := fs.Lookup("name").DefValue; got != "default" {
+		t.Errorf("default of -name = %q, want %q", got, "default")
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package conflagtest provides helpers for testing programs that use
+// conflag: it creates temporary configuration trees with fake home and
+// system configuration directories, isolates tests from configuration
+// files and environment variables of the machine running them, and
+// parses flag sets against them.
+//
+// Typical use:
+//
+//	func TestConfig(t *testing.T) {
+//		env := conflagtest.New(t)
+//		env.WriteGlobal("mycmd", "port = 80\n")
+//		env.WriteUser(".mycmd", "port = 8080\n")
+//		fs := env.NewFlagSet("mycmd")
+//		port := fs.Int("port", 0, "port")
+//		if err := env.Parse(fs); err != nil {
+//			t.Fatal(err)
+//		}
+//		if *port != 8080 {
+//			t.Errorf("port = %d, want 8080", *port)
+//		}
+//	}
+//
+// Because the home directory is set for all flag sets and environment
+// variables are set for the whole process, tests using Env must not run
+// in parallel.
+package conflagtest
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dchest/conflag"
+)
+
+// Environment variables, prefixed with the program name, that change
+// which configuration files are loaded.
+var configEnvSuffixes = []string{"CONFIG", "CONFIG_PATH", "SKIP_CONFIG", "NO_CONFIG", "PROFILE", "SYSCONFDIR"}
+
+// Env is an isolated environment with temporary directories for
+// configuration files.
+type Env struct {
+	t    testing.TB
+	Dir  string // root of the temporary tree
+	Home string // fake home directory of the current user
+	Etc  string // fake directory of global configuration files
+}
+
+// New returns a new environment in a temporary directory removed when the
+// test finishes. It makes the fake home directory the home directory of
+// the current user for conflag, and clears $XDG_CONFIG_HOME, so that the
+// user configuration directory is in it.
+func New(t testing.TB) *Env {
+	t.Helper()
+	dir := t.TempDir()
+	e := &Env{
+		t:    t,
+		Dir:  dir,
+		Home: filepath.Join(dir, "home"),
+		Etc:  filepath.Join(dir, "etc"),
+	}
+	for _, d := range []string{e.Home, e.Etc} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("HOME", e.Home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	conflag.SetHomeDir(e.Home)
+	t.Cleanup(func() { conflag.SetHomeDir("") })
+	return e
+}
+
+// WriteFile writes the file at the path relative to the root of the
+// temporary tree, creating parent directories, and returns its full path.
+// A path starting with "~/" is relative to the fake home directory.
+func (e *Env) WriteFile(path, content string) string {
+	e.t.Helper()
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		path = filepath.Join(e.Home, rest)
+	} else {
+		path = filepath.Join(e.Dir, path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		e.t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		e.t.Fatal(err)
+	}
+	return path
+}
+
+// WriteGlobal writes the file at the path relative to the fake directory
+// of global configuration files, such as "mycmd" or "mycmd/config", and
+// returns its full path.
+func (e *Env) WriteGlobal(path, content string) string {
+	e.t.Helper()
+	return e.WriteFile(filepath.Join("etc", path), content)
+}
+
+// WriteUser writes the file at the path relative to the fake home
+// directory, such as ".mycmd" or ".config/mycmd/config", and returns its
+// full path.
+func (e *Env) WriteUser(path, content string) string {
+	e.t.Helper()
+	return e.WriteFile(filepath.Join("home", path), content)
+}
+
+// Setenv sets the environment variable for the duration of the test, for
+// example, to set flags from LayerEnv.
+func (e *Env) Setenv(key, value string) {
+	e.t.Helper()
+	e.t.Setenv(key, value)
+}
+
+// NewFlagSet returns a new flag set with the given program name, which
+// loads global configuration files from the fake directory, returns
+// errors from Parse, and writes its output to the test log. Environment
+// variables with the program name prefix that select configuration files,
+// such as PROGNAME_CONFIG, are cleared for the duration of the test.
+func (e *Env) NewFlagSet(progName string) *conflag.FlagSet {
+	e.t.Helper()
+	fs := conflag.New(progName, flag.ContinueOnError)
+	fs.SetProgName(progName)
+	fs.SetSysConfDir(e.Etc)
+	fs.SetOutput(logWriter{e.t})
+	for _, suffix := range configEnvSuffixes {
+		e.t.Setenv(conflag.EnvName(progName, suffix), "")
+	}
+	return fs
+}
+
+// Parse parses the flag set with the given command-line arguments,
+// logging the configuration files it loaded.
+func (e *Env) Parse(fs *conflag.FlagSet, args ...string) error {
+	e.t.Helper()
+	err := fs.Parse(args)
+	e.t.Logf("configuration files used: %v", fs.ConfigFilesUsed())
+	return err
+}
+
+// logWriter writes to the test log.
+type logWriter struct {
+	t testing.TB
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w.t.Helper()
+	w.t.Log(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
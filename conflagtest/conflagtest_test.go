@@ -0,0 +1,33 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflagtest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsolation(t *testing.T) {
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "mycmd"), []byte("port=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MYCMD_SYSCONFDIR", outside)
+	t.Setenv("MYCMD_CONFIG", filepath.Join(outside, "mycmd"))
+
+	env := New(t)
+	env.WriteGlobal("mycmd", "port=80\n")
+	env.WriteUser(".mycmd", "name=user\n")
+	fs := env.NewFlagSet("mycmd")
+	port := fs.Int("port", 0, "port")
+	name := fs.String("name", "", "name")
+	if err := env.Parse(fs); err != nil {
+		t.Fatal(err)
+	}
+	if *port != 80 || *name != "user" {
+		t.Errorf("port=%d name=%q, want 80 and user", *port, *name)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MarkRequiredTogether makes Parse report an error if some, but not all,
+// of the named flags are set from any source, such as a configuration
+// file or the command line:
+//
+//	flag.MarkRequiredTogether("s3-bucket", "s3-region", "s3-key")
+//
+// The error names the flags that are missing. As for IsSet, values from
+// the configuration set with SetDefaultConfig don't count as set.
+func MarkRequiredTogether(names ...string) {
+	defaultSet.MarkRequiredTogether(names...)
+}
+
+// MarkRequiredTogether makes Parse report an error if some, but not all,
+// of the named flags are set. See the package-level MarkRequiredTogether.
+func (fs *FlagSet) MarkRequiredTogether(names ...string) {
+	if len(names) < 2 {
+		panic("conflag: MarkRequiredTogether called with fewer than two flags")
+	}
+	for _, name := range names {
+		if fs.FlagSet.Lookup(name) == nil {
+			panic(fmt.Sprintf("conflag: requiring undefined flag %s", name))
+		}
+	}
+	fs.requiredTogether = append(fs.requiredTogether, append([]string(nil), names...))
+}
+
+// checkConstraints returns an error if the flags set from all sources
+// violate constraints on them.
+func (fs *FlagSet) checkConstraints() error {
+	for _, names := range fs.requiredTogether {
+		var set, missing []string
+		for _, name := range names {
+			if fs.IsSet(name) {
+				set = append(set, "-"+name)
+			} else {
+				missing = append(missing, "-"+name)
+			}
+		}
+		if len(set) > 0 && len(missing) > 0 {
+			what := "flag"
+			if len(missing) > 1 {
+				what = "flags"
+			}
+			return fmt.Errorf("%s %s must be set together with %s",
+				what, strings.Join(missing, ", "), strings.Join(set, ", "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import "fmt"
+
+// Deprecate marks the named flag as deprecated. The flag keeps working,
+// but the first time it is set from a configuration file or the command
+// line, a warning with the given message is printed.
+func Deprecate(name, message string) {
+	defaultSet.Deprecate(name, message)
+}
+
+// Deprecate marks the named flag as deprecated.
+// See the package-level Deprecate.
+func (fs *FlagSet) Deprecate(name, message string) {
+	if fs.FlagSet.Lookup(name) == nil {
+		panic(fmt.Sprintf("conflag: deprecating undefined flag %s", name))
+	}
+	fs.deprecated[name] = message
+}
+
+// DeprecateAlias defines old as a deprecated alias for the flag named
+// newName, which must already be defined. Setting the flag under the old
+// name works, but prints a one-time warning pointing at the new name.
+// Use it to keep configuration files working after renaming a flag.
+func DeprecateAlias(old, newName string) {
+	defaultSet.DeprecateAlias(old, newName)
+}
+
+// DeprecateAlias defines old as a deprecated alias for the flag named
+// newName. See the package-level DeprecateAlias.
+func (fs *FlagSet) DeprecateAlias(old, newName string) {
+	fs.Alias(newName, old)
+	fs.deprecated[old] = fmt.Sprintf("use -%s instead", newName)
+}
+
+// warnDeprecated prints a warning if the name is deprecated and the
+// warning wasn't printed before.
+func (fs *FlagSet) warnDeprecated(name, source string) {
+	message, ok := fs.deprecated[name]
+	if !ok || fs.warnedDeprecated[name] {
+		return
+	}
+	fs.warnedDeprecated[name] = true
+	fs.logf(LevelWarn, "%s: flag -%s is deprecated: %s", source, name, message)
+}
@@ -0,0 +1,85 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SetDotEnvFile sets the path of a .env file, such as ".env" for the file
+// in the working directory, which is loaded after configuration files and
+// before the command line. Each line of the file has the form KEY=VALUE,
+// optionally preceded by "export". Keys are names of environment variables
+// for flags: the program name and the flag name in upper case, with
+// characters other than letters and digits replaced with underscores,
+// such as MYCMD_MAX_CONNS for the flag "max-conns" of program "mycmd".
+// Other keys are ignored, as is a missing file. Values may be quoted as
+// in configuration files; unquoted values end at " #", which starts a
+// comment.
+func SetDotEnvFile(path string) {
+	defaultSet.SetDotEnvFile(path)
+}
+
+// SetDotEnvFile sets the path of a .env file. See the package-level
+// SetDotEnvFile.
+func (fs *FlagSet) SetDotEnvFile(path string) {
+	fs.root().dotEnvFile = path
+}
+
+// readDotEnv reads the .env file and returns entries for defined flags.
+func (fs *FlagSet) readDotEnv(filename string) (entries []entry, err error) {
+	data, err := fs.readFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fs.logf(LevelDebug, ".env file %s not found", filename)
+			return nil, nil
+		}
+		return nil, fs.unreadable(fmt.Errorf("error opening .env file %q: %s", filename, err))
+	}
+	fs.markUsed(filename)
+	names := make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) {
+		names[fs.flagEnvName(f.Name)] = f.Name
+	})
+	scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(data, []byte(utf8BOM))))
+	scanner.Buffer(nil, fs.root().maxLineLength)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		e := entry{file: filename, line: n, hasValue: true}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, e.errorf("missing = in line: %s", line)
+		}
+		name, ok := names[strings.TrimSpace(key)]
+		if !ok {
+			continue
+		}
+		e.name = name
+		value = strings.TrimSpace(value)
+		if value != "" && value[0] != '"' && value[0] != '\'' {
+			value, _, _ = strings.Cut(value, " #")
+		}
+		e.literal = strings.HasPrefix(value, "'")
+		if e.value, err = unquoteValue(value); err != nil {
+			return nil, e.errorf("%s", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error parsing .env file %q: %s", filename, err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"flag"
+	"io"
+	"maps"
+	"reflect"
+)
+
+// Result is the outcome of DryRun.
+type Result struct {
+	Values   map[string]string // values of flags by name, sensitive ones masked
+	Sources  map[string]string // sources of the values, as returned by Source
+	Args     []string          // remaining non-flag arguments
+	Warnings []string          // warnings that Parse would print or log
+	Command  *Result           // result for the selected command, if any
+}
+
+// DryRun parses flags from all sources as Parse would, including
+// configuration files, environment variables, and the arguments, but
+// without changing the flags or the variables bound to them, and returns
+// the values that the flags would have and the first error, which Parse
+// would report. Programs don't exit and nothing is printed if there's an
+// error, regardless of the error handling property of the flag set.
+// It must be called on the top-level flag set.
+func DryRun(args []string) (Result, error) {
+	return defaultSet.DryRun(args)
+}
+
+// DryRun parses flags from all sources without changing them.
+// See the package-level DryRun.
+func (fs *FlagSet) DryRun(args []string) (Result, error) {
+	var warnings []string
+	s := fs.shadow(nil)
+	s.logger = func(level, msg string) {
+		if level == LevelWarn {
+			warnings = append(warnings, msg)
+		}
+	}
+	err := s.Parse(args)
+	if root := fs.root(); root.stdin == nil {
+		// Standard input can be read only once.
+		root.stdin = s.stdin
+	}
+	r := s.dryRunResult()
+	r.Warnings = warnings
+	return r, err
+}
+
+// shadow returns a copy of the flag set and its commands with the same
+// settings and new values of flags set to the defaults, for parsing
+// without changing the flag set.
+func (fs *FlagSet) shadow(parent *FlagSet) *FlagSet {
+	s := *fs
+	s.FlagSet = flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	s.FlagSet.SetOutput(io.Discard)
+	s.Usage = func() {}
+	s.parent = parent
+	s.selected = nil
+	s.dumpFlag, s.overridesFlag = "", ""
+	s.slogger = nil
+	s.configArgs, s.configArgsFrom = nil, ""
+	s.unusedKeys, s.filesUsed = nil, nil
+	s.sources = make(map[string]string)
+	s.pendingRefs = maps.Clone(fs.pendingRefs)
+	s.warnedDeprecated = make(map[string]bool)
+	fs.VisitAll(func(f *flag.Flag) {
+		s.FlagSet.Var(newValue(f), f.Name, f.Usage)
+	})
+	s.commands = make(map[string]*FlagSet)
+	for name, c := range fs.commands {
+		s.commands[name] = c.shadow(&s)
+	}
+	return &s
+}
+
+// newValue returns a new value of the flag's type set to the default
+// value, or a value holding the string if that's not possible.
+func newValue(f *flag.Flag) (v flag.Value) {
+	defer func() {
+		if recover() != nil {
+			v = &rawValue{f.DefValue, isBoolFlag(f)}
+		}
+	}()
+	if t := reflect.TypeOf(f.Value); t.Kind() == reflect.Pointer {
+		// Values holding lists may append on Set, so zero values
+		// matching the default are not set.
+		if nv, ok := reflect.New(t.Elem()).Interface().(flag.Value); ok && (nv.String() == f.DefValue || nv.Set(f.DefValue) == nil) {
+			return nv
+		}
+	}
+	return &rawValue{f.DefValue, isBoolFlag(f)}
+}
+
+// rawValue holds the string value of a flag.
+type rawValue struct {
+	value  string
+	isBool bool
+}
+
+func (v *rawValue) String() string     { return v.value }
+func (v *rawValue) Set(s string) error { v.value = s; return nil }
+func (v *rawValue) IsBoolFlag() bool   { return v.isBool }
+
+// dryRunResult returns the result of parsing the shadow flag set.
+func (fs *FlagSet) dryRunResult() Result {
+	r := Result{
+		Values:  make(map[string]string),
+		Sources: make(map[string]string),
+		Args:    fs.Args(),
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		r.Values[f.Name] = fs.displayValue(f)
+		r.Sources[f.Name] = fs.Source(f.Name)
+	})
+	if fs.selected != nil {
+		c := fs.selected.dryRunResult()
+		r.Command = &c
+	}
+	return r
+}
@@ -0,0 +1,73 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+// DuplicateKeyPolicy defines what happens when a key appears more than once
+// in the same section of a configuration file.
+type DuplicateKeyPolicy int
+
+// These constants cause parsing to behave as described if a configuration
+// file contains duplicate keys.
+const (
+	DuplicateKeyLastWins  DuplicateKeyPolicy = iota // Apply every entry, so the last one wins.
+	DuplicateKeyWarn                                // Print a warning and apply every entry.
+	DuplicateKeyFirstWins                           // Print a warning and skip all but the first entry.
+	DuplicateKeyError                               // Report an error.
+)
+
+// SetDuplicateKeyPolicy sets the policy for duplicate keys in configuration
+// files. The default is DuplicateKeyLastWins, which also allows setting
+// flags accepting multiple values, such as lists, by repeating the key.
+func SetDuplicateKeyPolicy(policy DuplicateKeyPolicy) {
+	defaultSet.SetDuplicateKeyPolicy(policy)
+}
+
+// SetDuplicateKeyPolicy sets the policy for duplicate keys in configuration
+// files. The default is DuplicateKeyLastWins.
+func (fs *FlagSet) SetDuplicateKeyPolicy(policy DuplicateKeyPolicy) {
+	fs.root().duplicateKeyPolicy = policy
+}
+
+// checkDuplicates applies the duplicate key policy to entries of one
+// configuration file, returning the entries to apply. Elements of arrays,
+// which are on the same line or marked by decoders that don't report
+// line numbers, are not duplicates.
+func (fs *FlagSet) checkDuplicates(entries []entry) ([]entry, error) {
+	policy := fs.root().duplicateKeyPolicy
+	if policy == DuplicateKeyLastWins {
+		return entries, nil
+	}
+	type key struct{ section, name string }
+	first := make(map[key]entry)
+	var result []entry
+	for _, e := range entries {
+		if fs.isArgsEntry(e) {
+			// Repeated to give multiple arguments.
+			result = append(result, e)
+			continue
+		}
+		k := key{e.section, fs.canonicalName(fs.normalizeKey(e.name))}
+		prev, ok := first[k]
+		if !ok {
+			first[k] = e
+		}
+		if !ok || e.nextElem || prev.line == e.line && e.line != 0 {
+			result = append(result, e)
+			continue
+		}
+		err := e.errorf("duplicate key %s, first at line %d", e.name, prev.line)
+		if prev.line == 0 {
+			err = e.errorf("duplicate key %s", e.name)
+		}
+		switch policy {
+		case DuplicateKeyError:
+			return nil, err
+		case DuplicateKeyWarn:
+			result = append(result, e)
+		}
+		fs.logf(LevelWarn, "%s", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,196 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// An Editor changes entries of a configuration file in the native format,
+// rewriting only the affected lines and keeping comments, blank lines,
+// and the order of other entries intact. Its methods can be chained:
+//
+//	err := flag.Edit(path).Set("port", "9090").Remove("debug").Save()
+type Editor struct {
+	fs   *FlagSet
+	path string
+	ops  []editOp
+	err  error
+}
+
+type editOp struct {
+	name   string
+	value  string
+	remove bool
+}
+
+// Edit returns an editor of the configuration file at path for flags of
+// the default set. The file is read and written by Save; it's created if
+// it doesn't exist.
+func Edit(path string) *Editor {
+	return defaultSet.Edit(path)
+}
+
+// Edit returns an editor of the configuration file at path for flags of
+// the set. See the package-level Edit.
+func (fs *FlagSet) Edit(path string) *Editor {
+	return &Editor{fs: fs, path: path}
+}
+
+// Set sets the value of the named flag. The first entry of the flag
+// outside of profile, host, and command sections is changed in place and
+// other such entries are removed; if there are none, the entry is added
+// before the first section. Entries of the flag include negated ones,
+// such as "no-debug", and obsolete keys migrated with MigrateKey, which
+// are replaced with the name of the flag. The value is checked when Set is called,
+// and the first error is returned by Save.
+func (ed *Editor) Set(name, value string) *Editor {
+	f := ed.fs.Lookup(ed.fs.normalizeKey(name))
+	if ed.err == nil {
+		e := entry{name: name, value: value, hasValue: true, literal: true, file: ed.path}
+		if f == nil {
+			ed.err = e.errorf("flag provided but not defined: -%s", name)
+		} else {
+			ed.err = ed.fs.validateValue(e, f)
+		}
+	}
+	if f != nil {
+		ed.ops = append(ed.ops, editOp{name: f.Name, value: value})
+	}
+	return ed
+}
+
+// Remove removes entries of the named flag, including negated and
+// obsolete ones, outside of profile, host, and command sections.
+func (ed *Editor) Remove(name string) *Editor {
+	ed.ops = append(ed.ops, editOp{name: ed.fs.canonicalName(ed.fs.normalizeKey(name)), remove: true})
+	return ed
+}
+
+// Save writes the changed file. It returns the first error of Set without
+// writing the file if there was one. The file is replaced atomically,
+// as by FlagSet.Save.
+func (ed *Editor) Save() error {
+	if ed.err != nil {
+		return ed.err
+	}
+	if format := ed.fs.configFormat(ed.path); format != "" {
+		return fmt.Errorf("conflag: can't edit configuration in %s format", format)
+	}
+	lines, bom, eol, err := readLines(ed.path)
+	if err != nil {
+		return err
+	}
+	perm := os.FileMode(0644)
+	for _, op := range ed.ops {
+		lines = ed.apply(lines, op)
+		if !op.remove && ed.fs.IsSensitive(op.name) {
+			perm = 0600
+		}
+	}
+	var b strings.Builder
+	b.WriteString(bom)
+	for _, line := range lines {
+		b.WriteString(line + eol)
+	}
+	return ed.fs.writeFile(ed.path, []byte(b.String()), perm)
+}
+
+// apply returns the lines changed by the operation.
+func (ed *Editor) apply(lines []string, op editOp) []string {
+	var result []string
+	section := ""
+	firstSection := -1
+	done := op.remove
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		// Find continuation lines of the entry.
+		j := i
+		key := configKey(line)
+		if key != "" {
+			for j+1 < len(lines) && strings.HasSuffix(strings.TrimSpace(lines[j]), `\`) {
+				j++
+			}
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "[") {
+			if s, err := parseSectionHeader(strings.TrimSpace(line)); err == nil {
+				section = s
+			}
+			if firstSection < 0 {
+				firstSection = len(result)
+			}
+		}
+		name := key
+		if key != "" && section != "" {
+			name = ""
+			if isPrefixSection(section) {
+				name = section + "." + key
+			}
+		}
+		if name == "" || ed.fs.keyFlag(name, strings.Contains(line, "=")) != op.name {
+			result = append(result, lines[i:j+1]...)
+			i = j
+			continue
+		}
+		if !done {
+			// Keep the key as written, without the prefix of the section,
+			// unless it's a negated or obsolete key.
+			ok := true
+			if ed.fs.Lookup(ed.fs.normalizeKey(name)) == nil {
+				key, ok = sectionKey(section, op.name)
+			}
+			if ok {
+				result = append(result, key+"="+quoteValue(op.value))
+				done = true
+			}
+		}
+		i = j
+	}
+	if !done {
+		line := op.name + "=" + quoteValue(op.value)
+		if firstSection < 0 {
+			result = append(result, line)
+		} else {
+			// Keep blank lines before the section.
+			for firstSection > 0 && strings.TrimSpace(result[firstSection-1]) == "" {
+				firstSection--
+			}
+			result = append(result[:firstSection], append([]string{line}, result[firstSection:]...)...)
+		}
+	}
+	return result
+}
+
+// keyFlag returns the name of the flag set by the configuration file
+// entry with the key, resolved as by Parse, including negated boolean
+// flags, if the entry has no value, and keys migrated with MigrateKey.
+// It returns an empty string if the key doesn't refer to a flag.
+func (fs *FlagSet) keyFlag(key string, hasValue bool) string {
+	if f := fs.Lookup(fs.normalizeKey(key)); f != nil {
+		return f.Name
+	}
+	if !hasValue {
+		if f := fs.lookupNegated(key); f != nil {
+			return f.Name
+		}
+	}
+	if newName, ok := fs.migratedName(key); ok {
+		return newName
+	}
+	return ""
+}
+
+// sectionKey returns the key of the named flag in the section, which is
+// the name without the prefix of the section, and reports whether the
+// flag can be set in the section.
+func sectionKey(section, name string) (string, bool) {
+	if section == "" {
+		return name, true
+	}
+	key, ok := strings.CutPrefix(name, section+".")
+	return key, ok && isPrefixSection(section)
+}
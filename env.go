@@ -0,0 +1,186 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// EnvName returns the name of the program-specific environment variable
+// with the given suffix, as read by conflag, such as MYCMD_PROFILE for
+// program name "mycmd" and suffix "PROFILE": the program name converted
+// to upper case, with characters other than letters and digits replaced
+// with underscores, followed by an underscore and the suffix.
+func EnvName(progName, suffix string) string {
+	return envSafe(progName) + "_" + suffix
+}
+
+// envName returns the name of the program-specific environment variable
+// with the given suffix.
+func (fs *FlagSet) envName(suffix string) string {
+	return EnvName(fs.ProgName(), suffix)
+}
+
+// flagEnvName returns the name of the environment variable for the named
+// flag, such as MYCMD_MAX_CONNS for the flag "max-conns" of program
+// "mycmd".
+func (fs *FlagSet) flagEnvName(name string) string {
+	return fs.envName(envSafe(name))
+}
+
+// envSafe converts s to upper case, replacing characters other than ASCII
+// letters and digits with underscores.
+func envSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return '_'
+		}
+		return unicode.ToUpper(r)
+	}, s)
+}
+
+// BindEnv makes the environment variable, if set, provide the value of
+// the named flag when no other source sets it, so that the variable
+// overrides the default value, but not configuration files or the command
+// line. Unlike variables of LayerEnv, its name is arbitrary, such as
+// LISTEN_ADDR. Usage output mentions the variable. The source of a value
+// from the variable is its name preceded by "$". An invalid value is
+// reported as a configuration error by Parse.
+func BindEnv(name, envVar string) {
+	defaultSet.BindEnv(name, envVar)
+}
+
+// BindEnv makes the environment variable provide the value of the named
+// flag. See the package-level BindEnv.
+func (fs *FlagSet) BindEnv(name, envVar string) {
+	if fs.FlagSet.Lookup(name) == nil {
+		panic(fmt.Sprintf("conflag: binding environment variable to undefined flag %s", name))
+	}
+	if fs.envVars == nil {
+		fs.envVars = make(map[string]string)
+	}
+	fs.envVars[name] = envVar
+}
+
+// StringEnv defines a string flag with specified name, default value, and
+// usage string, whose value is taken from the environment variable, if
+// set, unless another source sets it. See BindEnv.
+func StringEnv(name, envVar, value, usage string) *string {
+	return defaultSet.StringEnv(name, envVar, value, usage)
+}
+
+// StringEnv defines a string flag whose value is taken from the
+// environment variable, if set. See the package-level StringEnv.
+func (fs *FlagSet) StringEnv(name, envVar, value, usage string) *string {
+	p := fs.String(name, value, usage)
+	fs.BindEnv(name, envVar)
+	return p
+}
+
+// IntEnv defines an int flag whose value is taken from the environment
+// variable, if set. See StringEnv.
+func IntEnv(name, envVar string, value int, usage string) *int {
+	return defaultSet.IntEnv(name, envVar, value, usage)
+}
+
+// IntEnv defines an int flag whose value is taken from the environment
+// variable, if set. See the package-level StringEnv.
+func (fs *FlagSet) IntEnv(name, envVar string, value int, usage string) *int {
+	p := fs.Int(name, value, usage)
+	fs.BindEnv(name, envVar)
+	return p
+}
+
+// BoolEnv defines a bool flag whose value is taken from the environment
+// variable, if set. See StringEnv.
+func BoolEnv(name, envVar string, value bool, usage string) *bool {
+	return defaultSet.BoolEnv(name, envVar, value, usage)
+}
+
+// BoolEnv defines a bool flag whose value is taken from the environment
+// variable, if set. See the package-level StringEnv.
+func (fs *FlagSet) BoolEnv(name, envVar string, value bool, usage string) *bool {
+	p := fs.Bool(name, value, usage)
+	fs.BindEnv(name, envVar)
+	return p
+}
+
+// applyBoundEnv sets flags from environment variables bound with BindEnv.
+func (fs *FlagSet) applyBoundEnv() error {
+	for name, envVar := range fs.envVars {
+		value, ok := os.LookupEnv(envVar)
+		if !ok {
+			continue
+		}
+		f := fs.FlagSet.Lookup(name)
+		if err := fs.setFlag(f, name, value, "$"+envVar); err != nil {
+			if fs.IsSensitive(name) {
+				return fmt.Errorf("invalid value of $%s for flag -%s: %v", envVar, name, err)
+			}
+			return fmt.Errorf("invalid value %q of $%s for flag -%s: %v", value, envVar, name, err)
+		}
+	}
+	return nil
+}
+
+// WriteEnv writes to w a line of the form NAME=value for each flag, in
+// lexicographical order of flags, with the current value of the flag.
+// The name is the prefix followed by the flag name converted to upper
+// case, with characters other than letters and digits replaced with
+// underscores. If the prefix is empty, it's the program name converted
+// in the same way followed by an underscore, so that the names are those
+// read by LayerEnv, such as MYCMD_MAX_CONNS. Values are put in double
+// quotes with backslash escapes when needed, so the output can be
+// evaluated by shell scripts or used as a systemd environment file to
+// pass the configuration to child processes. Sensitive flags are
+// written commented out, with their values masked, so that secrets don't
+// leak into the environment of other processes.
+func WriteEnv(w io.Writer, prefix string) error {
+	return defaultSet.WriteEnv(w, prefix)
+}
+
+// WriteEnv writes to w the current values of flags as environment
+// variable assignments. See the package-level WriteEnv.
+func (fs *FlagSet) WriteEnv(w io.Writer, prefix string) error {
+	var b strings.Builder
+	fs.VisitAll(func(f *flag.Flag) {
+		name := fs.flagEnvName(f.Name)
+		if prefix != "" {
+			name = prefix + envSafe(f.Name)
+		}
+		if fs.IsSensitive(f.Name) {
+			fmt.Fprintf(&b, "#%s=%s\n", name, mask)
+			return
+		}
+		fmt.Fprintf(&b, "%s=%s\n", name, shellQuote(f.Value.String()))
+	})
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// shellQuote returns s in double quotes, with backslashes before the
+// characters special in them, '"', '\', '$' and '`', unless it consists
+// only of characters that don't need quoting. Both the shell and systemd
+// environment files read such strings as s.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789@%+=:,./_-") == "" {
+		return s
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, c := range s {
+		if strings.ContainsRune(`"\$`+"`", c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
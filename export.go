@@ -0,0 +1,144 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"encoding/json"
+	"expvar"
+	"flag"
+	"io"
+	"time"
+)
+
+// AllSettings returns the effective configuration: the current values of
+// all flags by name, typed as returned by the Get method of flag.Getter,
+// such as int or time.Duration, or as strings for flags that don't
+// implement it. Values of sensitive flags are masked. It's synchronized
+// with changes of flags at run time.
+func AllSettings() map[string]interface{} {
+	return defaultSet.AllSettings()
+}
+
+// AllSettings returns the current values of all flags by name.
+// See the package-level AllSettings.
+func (fs *FlagSet) AllSettings() map[string]interface{} {
+	runtimeMu.RLock()
+	defer runtimeMu.RUnlock()
+	m := make(map[string]interface{})
+	fs.VisitAll(func(f *flag.Flag) {
+		m[f.Name] = fs.settingValue(f)
+	})
+	return m
+}
+
+// settingValue returns the typed current value of the flag, masking it
+// if the flag is sensitive.
+func (fs *FlagSet) settingValue(f *flag.Flag) interface{} {
+	if fs.IsSensitive(f.Name) {
+		return mask
+	}
+	return flagValue(f)
+}
+
+// jsonFlag is the JSON representation of a flag.
+type jsonFlag struct {
+	Name      string `json:"name"`
+	Value     string `json:"value"`
+	Default   string `json:"default"`
+	Source    string `json:"source"`
+	Sensitive bool   `json:"sensitive,omitempty"`
+}
+
+// WriteJSON writes to w the effective configuration as an indented JSON
+// array of objects, one for each flag in lexicographical order, with
+// the members "name", "value", "default", and "source", holding strings
+// as returned by Source and the String method of flag values, and
+// "sensitive" set to true for sensitive flags, whose values are masked:
+//
+//	[
+//	  {
+//	    "name": "port",
+//	    "value": "8080",
+//	    "default": "80",
+//	    "source": "/etc/mycmd"
+//	  }
+//	]
+func WriteJSON(w io.Writer) error {
+	return defaultSet.WriteJSON(w)
+}
+
+// WriteJSON writes to w the effective configuration in JSON.
+// See the package-level WriteJSON.
+func (fs *FlagSet) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(fs.jsonFlags(), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// MarshalJSON returns the effective configuration in the format written
+// by WriteJSON, without indentation.
+func (fs *FlagSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fs.jsonFlags())
+}
+
+// jsonFlags returns JSON representations of all flags. It's synchronized
+// with changes of flags at run time.
+func (fs *FlagSet) jsonFlags() []jsonFlag {
+	runtimeMu.RLock()
+	defer runtimeMu.RUnlock()
+	flags := []jsonFlag{}
+	fs.VisitAll(func(f *flag.Flag) {
+		j := jsonFlag{
+			Name:    f.Name,
+			Value:   f.Value.String(),
+			Default: f.DefValue,
+			Source:  fs.Source(f.Name),
+		}
+		if fs.IsSensitive(f.Name) {
+			j.Value, j.Default, j.Sensitive = mask, mask, true
+		}
+		flags = append(flags, j)
+	})
+	return flags
+}
+
+// PublishExpvar publishes the effective configuration of the default set
+// as the expvar variable "flags", so that it can be inspected through the
+// /debug/vars endpoint. See FlagSet.PublishExpvar.
+func PublishExpvar() {
+	defaultSet.PublishExpvar("flags")
+}
+
+// PublishExpvar publishes the effective configuration as the expvar
+// variable with the given name: an object with the members "values",
+// mapping flag names to current values typed as by AllSettings, and
+// "sources", mapping them to sources of the values, and "reloads", with
+// the numbers of successful and failed calls to Reload and the time of
+// the last one, if any. Sensitive flags are omitted. The values are read
+// each time the variable is requested, synchronized with changes of flags
+// at run time. Like expvar.Publish, it panics if the name is already registered.
+func (fs *FlagSet) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() any {
+		runtimeMu.RLock()
+		defer runtimeMu.RUnlock()
+		values := make(map[string]interface{})
+		sources := make(map[string]string)
+		fs.VisitAll(func(f *flag.Flag) {
+			if !fs.IsSensitive(f.Name) {
+				values[f.Name] = fs.settingValue(f)
+				sources[f.Name] = fs.Source(f.Name)
+			}
+		})
+		r := fs.reloads
+		reloads := map[string]interface{}{"succeeded": r.succeeded, "failed": r.failed}
+		if !r.last.IsZero() {
+			reloads["last"] = r.last.Format(time.RFC3339)
+		}
+		return map[string]interface{}{"values": values, "sources": sources, "reloads": reloads}
+	}))
+}
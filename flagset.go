@@ -0,0 +1,295 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A FlagSet represents a set of defined flags, which are filled from
+// configuration files before parsing the command line. It embeds
+// flag.FlagSet, so flags are defined with the usual methods.
+//
+// The package-level functions operate on the default set, which is
+// parsed from os.Args.
+type FlagSet struct {
+	*flag.FlagSet
+
+	parent    *FlagSet            // parent set of a command
+	command   string              // command name
+	commands  map[string]*FlagSet // commands by name
+	selected  *FlagSet            // command selected by Parse
+	arguments []string            // arguments passed to Parse
+
+	// Settings; commands use settings of the root set.
+	progName             string
+	progNameAliases      []string
+	normalize            func(name string) string
+	unknownKeyPolicy     UnknownKeyPolicy
+	duplicateKeyPolicy   DuplicateKeyPolicy
+	unreadableFilePolicy UnreadableFilePolicy
+	expandEnv            bool
+	maxLineLength        int
+	negation             bool // negated boolean flags on the command line
+	warnMigrated         bool
+	profile              string
+	profileFlag          string
+	dumpFlag             string
+	overridesFlag        string
+	format               string // configuration file format
+	dotEnvFile           string
+	defaultConfig        []byte
+	searchPaths          []string
+	noGlobalConfig       bool
+	noUserConfig         bool
+	noConfigFlag         string
+	configFlag           string
+	configEnv            string // environment variable listing configuration files
+	stdin                []byte // configuration read from the standard input
+	sysConfDir           string
+	globalPatterns       []string
+	userPatterns         []string
+	precedence           []Layer
+	unusedKeys           []KeyInfo
+	keepBackup           bool
+	secureConfig         bool // refuse insecure configuration files
+	missingHomePolicy    MissingHomePolicy
+	userConfigSkipped    bool   // user configuration file skipped because of missing home
+	argsKey              string // key of positional arguments in configuration files
+	logger               func(level, msg string)
+	slogger              *slog.Logger
+	trace                io.Writer
+	filesUsed            []string // configuration files loaded by Parse
+
+	sources          map[string]string // flag names to sources of values
+	aliases          map[string]string // aliases to canonical names
+	deprecated       map[string]string // deprecated names to messages
+	migrations       map[string]string // obsolete keys to flag names
+	warnedDeprecated map[string]bool
+	sensitive        map[string]bool
+	hidden           map[string]bool
+	groups           []*flagGroup
+	grouped          map[string]bool
+	pendingRefs      map[string]entry
+	keyring          map[string]keyringItem // flag names to keyring items
+	probes           []flagProbe
+	requiredTogether [][]string
+	annotations      map[string]map[string]string
+	configOnly       map[string]bool
+	cliOnly          map[string]bool
+	mutable          map[string]bool // flags changeable at run time
+	onChange         []func(name, oldValue, newValue string)
+	configArgs       []string          // positional arguments from configuration files
+	configArgsFrom   string            // file and section of configArgs
+	envVars          map[string]string // flag names to bound environment variables
+	reloadable       map[string]bool
+	listModes        map[string]ListMode
+	listDelims       map[string]string
+	reloads          reloadStats
+}
+
+// New returns a new, empty flag set with the specified name and error
+// handling property, which fills flags from configuration files.
+func New(name string, errorHandling flag.ErrorHandling) *FlagSet {
+	fs := &FlagSet{
+		FlagSet:          flag.NewFlagSet(name, errorHandling),
+		commands:         make(map[string]*FlagSet),
+		maxLineLength:    1 << 20,
+		sources:          make(map[string]string),
+		aliases:          make(map[string]string),
+		deprecated:       make(map[string]string),
+		migrations:       make(map[string]string),
+		warnedDeprecated: make(map[string]bool),
+		sensitive:        make(map[string]bool),
+		hidden:           make(map[string]bool),
+		grouped:          make(map[string]bool),
+		pendingRefs:      make(map[string]entry),
+	}
+	fs.Usage = fs.defaultUsage
+	return fs
+}
+
+// root returns the flag set at the top of the command hierarchy,
+// which holds the settings.
+func (fs *FlagSet) root() *FlagSet {
+	for fs.parent != nil {
+		fs = fs.parent
+	}
+	return fs
+}
+
+// SetProgName sets program name, which is used for locating configuration
+// file, and outputting usage or error information.
+//
+// If program name is not set, the flag set won't use configuration file,
+// only command line arguments.
+func (fs *FlagSet) SetProgName(name string) {
+	if strings.ContainsRune(name, filepath.Separator) {
+		panic(fmt.Sprintf("conflag: SetProgName called with bad program name %q", name))
+	}
+	fs.root().progName = name
+}
+
+// ProgName returns the program name.
+func (fs *FlagSet) ProgName() string {
+	return fs.root().progName
+}
+
+// Command returns the flag set for the named subcommand, creating it on the
+// first call. When Parse finds the command name as the first non-flag
+// argument, it parses the rest of the arguments with the command's flag
+// set. Configuration files for commands are shared with the parent set:
+// the command's flags are filled from keys outside of sections, then from
+// the "[command name]" section, and then from the command line.
+func (fs *FlagSet) Command(name string) *FlagSet {
+	if c, ok := fs.commands[name]; ok {
+		return c
+	}
+	c := New(name, fs.ErrorHandling())
+	c.SetOutput(fs.Output())
+	c.parent = fs
+	c.command = name
+	fs.commands[name] = c
+	return c
+}
+
+// Commands returns the names of defined commands in lexicographical order.
+func (fs *FlagSet) Commands() []string {
+	names := make([]string, 0, len(fs.commands))
+	for name := range fs.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Selected returns the flag set of the command selected by the arguments
+// during Parse, or nil if no command was selected.
+func (fs *FlagSet) Selected() *FlagSet {
+	return fs.selected
+}
+
+// CommandName returns the name of the command that the flag set belongs
+// to, or an empty string for the top-level flag set.
+func (fs *FlagSet) CommandName() string {
+	return fs.command
+}
+
+// Parse parses flag definitions from the configuration files, if program
+// name is set, and then from the argument list, which should not include
+// the program name. The order of these sources can be changed with
+// SetPrecedence. Must be called after all flags in the FlagSet are
+// defined and before flags are accessed by the program. If the first
+// non-flag argument names a command, the command's flag set is parsed
+// from the rest of the arguments. Parse may be called again, for example,
+// in tests, to set flags from different files or arguments; flags not set
+// again keep their values.
+func (fs *FlagSet) Parse(arguments []string) error {
+	fs.resetParse()
+	fs.arguments = arguments
+	var args []string
+	envApplied := false
+	for _, layer := range fs.root().layers() {
+		if layer != LayerDefault && !envApplied {
+			// Variables bound with BindEnv override only defaults.
+			if err := fs.applyBoundEnv(); err != nil {
+				return fs.failConfig(err)
+			}
+			envApplied = true
+		}
+		if layer == LayerCommandLine {
+			var err error
+			if args, err = fs.parseArgs(arguments, SourceCommandLine); err != nil {
+				return err
+			}
+		} else if fs.ProgName() != "" || layer == LayerDefault {
+			if err := fs.loadLayer(layer); err != nil {
+				return err
+			}
+		}
+	}
+	if !envApplied {
+		if err := fs.applyBoundEnv(); err != nil {
+			return fs.failConfig(err)
+		}
+	}
+	entries, err := fs.keyringEntries()
+	if err == nil {
+		err = fs.applyEntries(entries)
+	}
+	if err != nil {
+		return fs.failConfig(err)
+	}
+	if err := fs.finishConfig(); err != nil {
+		return fs.failConfig(err)
+	}
+	var c *FlagSet
+	if len(args) > 0 {
+		c = fs.commands[args[0]]
+	}
+	if c == nil && len(fs.configArgs) > 0 {
+		args = append(append([]string(nil), fs.configArgs...), args...)
+	}
+	// Mark the set as parsed and store the remaining arguments.
+	fs.FlagSet.Parse(append([]string{"--"}, args...))
+	if fs.dumpFlag != "" {
+		if f := fs.FlagSet.Lookup(fs.dumpFlag); f != nil && f.Value.String() == "true" {
+			fs.printConfig(os.Stdout, false)
+			return fs.printed()
+		}
+	}
+	if fs.overridesFlag != "" {
+		if f := fs.FlagSet.Lookup(fs.overridesFlag); f != nil && f.Value.String() == "true" {
+			fs.printConfig(os.Stdout, true)
+			return fs.printed()
+		}
+	}
+	if c != nil {
+		fs.selected = c
+		return c.Parse(args[1:])
+	}
+	return nil
+}
+
+// printed exits the program after the configuration was printed by the
+// flag enabled with EnableDumpFlag or EnableOverridesFlag if the set exits
+// on errors, and otherwise returns flag.ErrHelp, so that the caller can
+// stop.
+func (fs *FlagSet) printed() error {
+	if fs.ErrorHandling() == flag.ExitOnError {
+		os.Exit(0)
+	}
+	return flag.ErrHelp
+}
+
+// finishConfig resolves references to other flags in values and checks
+// constraints and values of flags after all sources are applied.
+func (fs *FlagSet) finishConfig() error {
+	if err := fs.resolveRefs(); err != nil {
+		return err
+	}
+	if err := fs.checkConstraints(); err != nil {
+		return err
+	}
+	return fs.checkValues()
+}
+
+// Lookup returns the Flag structure of the named flag, returning nil if
+// none exists. The name may be an alias.
+func (fs *FlagSet) Lookup(name string) *flag.Flag {
+	return fs.FlagSet.Lookup(fs.canonicalName(name))
+}
+
+// Set sets the value of the named flag. The name may be an alias.
+func (fs *FlagSet) Set(name, value string) error {
+	return fs.FlagSet.Set(fs.canonicalName(name), value)
+}
@@ -0,0 +1,230 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// formats maps names of configuration file formats, which are also file
+// name extensions, to functions decoding entries from file contents.
+var formats = map[string]func(filename string, data []byte) ([]entry, error){
+	"json":       decodeJSON,
+	"properties": decodeProperties,
+	"toml":       decodeTOML,
+}
+
+// RegisterFormat registers a decoder for configuration files with the
+// given file name extension, such as "hcl", which also names the format
+// for SetConfigFormat. The decode function returns flag names, which may
+// be dotted, mapped to values from the file contents. Registering an
+// extension of a built-in format replaces its decoder. RegisterFormat
+// should be called before Parse.
+func RegisterFormat(ext string, decode func(data []byte) (map[string]string, error)) {
+	ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+	if ext == "" || decode == nil {
+		panic("conflag: RegisterFormat called with empty extension or nil decoder")
+	}
+	formats[ext] = func(filename string, data []byte) ([]entry, error) {
+		m, err := decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", filename, err)
+		}
+		names := make([]string, 0, len(m))
+		for name := range m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		entries := make([]entry, len(names))
+		for i, name := range names {
+			entries[i] = entry{name: name, value: m[name], hasValue: true, file: filename}
+		}
+		return entries, nil
+	}
+}
+
+// SetConfigFormat sets the format of configuration files, such as "toml".
+// The format name is appended as an extension to configuration file names,
+// so that only "/etc/progname.toml" and "$HOME/.progname.toml" are loaded.
+// By default, or if the name is empty, the format is detected from the
+// extension of the existing file. Files with an extension of a known format
+// are decoded in that format regardless of this setting. SetConfigFormat
+// panics if the format is unknown.
+func SetConfigFormat(name string) {
+	defaultSet.SetConfigFormat(name)
+}
+
+// SetConfigFormat sets the format of configuration files. See the
+// package-level SetConfigFormat.
+func (fs *FlagSet) SetConfigFormat(name string) {
+	if _, ok := formats[name]; !ok && name != "" {
+		panic("conflag: unknown configuration format " + name)
+	}
+	fs.root().format = name
+}
+
+// findConfigFile returns the path of the configuration file with the
+// given path without extension, and whether the file exists. If the format
+// is selected, the path has the format's extension. Otherwise it's the
+// existing file among the base path and the paths with ".conf" or an
+// extension of a known format, or the base path if there's none. If
+// several files exist, it returns the first of them and an error.
+func (fs *FlagSet) findConfigFile(base string) (string, bool, error) {
+	if format := fs.root().format; format != "" {
+		path := base
+		if !strings.EqualFold(filepath.Ext(base), "."+format) {
+			path += "." + format
+		}
+		return path, fs.probe(path), nil
+	}
+	exts := make([]string, 0, len(formats))
+	for ext := range formats {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	var found []string
+	for _, ext := range append([]string{"", "conf"}, exts...) {
+		path := base
+		if ext != "" {
+			path += "." + ext
+		}
+		if fs.probe(path) {
+			found = append(found, path)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return base, false, nil
+	case 1:
+		return found[0], true, nil
+	}
+	return found[0], true, fmt.Errorf("ambiguous configuration files: %s", strings.Join(found, ", "))
+}
+
+// probe reports whether the file exists and isn't a directory.
+func (fs *FlagSet) probe(path string) bool {
+	fi, err := os.Stat(path)
+	switch {
+	case err == nil && !fi.IsDir():
+		fs.tracef("probe %s: found", path)
+		return true
+	case err == nil:
+		fs.tracef("probe %s: directory", path)
+	case os.IsNotExist(err):
+		fs.tracef("probe %s: not found", path)
+	default:
+		fs.tracef("probe %s: %v", path, err)
+	}
+	return false
+}
+
+// configFormat returns the format of the named configuration file: the
+// format named by its extension, if known, or the selected format.
+func (fs *FlagSet) configFormat(filename string) string {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+	if _, ok := formats[ext]; ok {
+		return ext
+	}
+	return fs.root().format
+}
+
+// decodeTree returns entries of the decoded configuration document v,
+// which must be a map.
+func decodeTree(filename string, v any) ([]entry, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if reflect.ValueOf(v).Kind() != reflect.Map {
+		return nil, fmt.Errorf("%s: configuration must be a map of keys to values", filename)
+	}
+	var entries []entry
+	if err := flattenValue(&entries, filename, "", v); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return entries, nil
+}
+
+// flattenValue appends entries for the decoded value v of the named key:
+// keys of nested maps are joined to the name with dots, and each element
+// of a slice is a separate entry for the same flag, as if the flag was
+// repeated on the command line.
+func flattenValue(entries *[]entry, filename, name string, v any) error {
+	add := func(value string) {
+		*entries = append(*entries, entry{
+			name:     name,
+			value:    value,
+			hasValue: true,
+			file:     filename,
+		})
+	}
+	switch v := v.(type) {
+	case nil:
+		// Null values leave the flag unchanged.
+	case string:
+		add(v)
+	case bool:
+		add(strconv.FormatBool(v))
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1e21 {
+			// Integers are decoded as floats by some decoders.
+			add(strconv.FormatFloat(v, 'f', -1, 64))
+		} else {
+			add(strconv.FormatFloat(v, 'g', -1, 64))
+		}
+	case time.Time:
+		add(v.Format(time.RFC3339Nano))
+	case fmt.Stringer:
+		add(v.String())
+	default:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Map:
+			values := make(map[string]any, rv.Len())
+			keys := make([]string, 0, rv.Len())
+			for it := rv.MapRange(); it.Next(); {
+				k := fmt.Sprint(it.Key().Interface())
+				values[k] = it.Value().Interface()
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				key := k
+				if name != "" {
+					key = name + "." + k
+				}
+				if err := flattenValue(entries, filename, key, values[k]); err != nil {
+					return err
+				}
+			}
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < rv.Len(); i++ {
+				elem := rv.Index(i).Interface()
+				if elem != nil {
+					if k := reflect.TypeOf(elem).Kind(); k == reflect.Map || k == reflect.Slice {
+						return fmt.Errorf("nested arrays and maps in arrays are not supported: %s", name)
+					}
+				}
+				n := len(*entries)
+				if err := flattenValue(entries, filename, name, elem); err != nil {
+					return err
+				}
+				if i > 0 && len(*entries) > n {
+					(*entries)[n].nextElem = true
+				}
+			}
+		default:
+			add(fmt.Sprint(v))
+		}
+	}
+	return nil
+}
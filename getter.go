@@ -0,0 +1,142 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+// GetString returns the current value of the named string flag, which
+// may be an alias, so that code knowing only flag names doesn't need the
+// pointers returned when defining flags. It returns an error if the flag
+// isn't defined or holds a value of another type. Getters for other types
+// behave in the same way.
+func GetString(name string) (string, error) {
+	return defaultSet.GetString(name)
+}
+
+// GetString returns the value of the named string flag.
+func (fs *FlagSet) GetString(name string) (string, error) {
+	return getValue[string](fs, name, "string")
+}
+
+// GetBool returns the value of the named bool flag.
+func GetBool(name string) (bool, error) {
+	return defaultSet.GetBool(name)
+}
+
+// GetBool returns the value of the named bool flag.
+func (fs *FlagSet) GetBool(name string) (bool, error) {
+	return getValue[bool](fs, name, "bool")
+}
+
+// GetInt returns the value of the named int flag.
+func GetInt(name string) (int, error) {
+	return defaultSet.GetInt(name)
+}
+
+// GetInt returns the value of the named int flag.
+func (fs *FlagSet) GetInt(name string) (int, error) {
+	return getValue[int](fs, name, "int")
+}
+
+// GetInt64 returns the value of the named int64 flag.
+func GetInt64(name string) (int64, error) {
+	return defaultSet.GetInt64(name)
+}
+
+// GetInt64 returns the value of the named int64 flag.
+func (fs *FlagSet) GetInt64(name string) (int64, error) {
+	return getValue[int64](fs, name, "int64")
+}
+
+// GetUint returns the value of the named uint flag.
+func GetUint(name string) (uint, error) {
+	return defaultSet.GetUint(name)
+}
+
+// GetUint returns the value of the named uint flag.
+func (fs *FlagSet) GetUint(name string) (uint, error) {
+	return getValue[uint](fs, name, "uint")
+}
+
+// GetUint64 returns the value of the named uint64 flag.
+func GetUint64(name string) (uint64, error) {
+	return defaultSet.GetUint64(name)
+}
+
+// GetUint64 returns the value of the named uint64 flag.
+func (fs *FlagSet) GetUint64(name string) (uint64, error) {
+	return getValue[uint64](fs, name, "uint64")
+}
+
+// GetFloat64 returns the value of the named float64 flag.
+func GetFloat64(name string) (float64, error) {
+	return defaultSet.GetFloat64(name)
+}
+
+// GetFloat64 returns the value of the named float64 flag.
+func (fs *FlagSet) GetFloat64(name string) (float64, error) {
+	return getValue[float64](fs, name, "float64")
+}
+
+// GetDuration returns the value of the named time.Duration flag.
+func GetDuration(name string) (time.Duration, error) {
+	return defaultSet.GetDuration(name)
+}
+
+// GetDuration returns the value of the named time.Duration flag.
+func (fs *FlagSet) GetDuration(name string) (time.Duration, error) {
+	return getValue[time.Duration](fs, name, "time.Duration")
+}
+
+// Value returns the current value of the named flag, which may be an
+// alias, typed as returned by the Get method of flag.Getter, such as int
+// or time.Duration, or its string form for flags that don't implement
+// it. It reports whether the flag is defined. Values of sensitive flags
+// are not masked.
+func Value(name string) (interface{}, bool) {
+	return defaultSet.Value(name)
+}
+
+// Value returns the current value of the named flag. See the
+// package-level Value.
+func (fs *FlagSet) Value(name string) (interface{}, bool) {
+	f := fs.Lookup(name)
+	if f == nil {
+		return nil, false
+	}
+	runtimeMu.RLock()
+	defer runtimeMu.RUnlock()
+	return flagValue(f), true
+}
+
+// flagValue returns the typed current value of the flag, or its string
+// form if the flag doesn't implement flag.Getter.
+func flagValue(f *flag.Flag) interface{} {
+	if g, ok := f.Value.(flag.Getter); ok {
+		return g.Get()
+	}
+	return f.Value.String()
+}
+
+// getValue returns the value of the named flag of type T.
+func getValue[T any](fs *FlagSet, name, typeName string) (T, error) {
+	var zero T
+	f := fs.Lookup(name)
+	if f == nil {
+		return zero, fmt.Errorf("conflag: flag -%s is not defined", name)
+	}
+	runtimeMu.RLock()
+	defer runtimeMu.RUnlock()
+	if g, ok := f.Value.(flag.Getter); ok {
+		if v, ok := g.Get().(T); ok {
+			return v, nil
+		}
+	}
+	return zero, fmt.Errorf("conflag: flag -%s doesn't hold a value of type %s", name, typeName)
+}
@@ -0,0 +1,5 @@
+module github.com/dchest/conflag
+
+go 1.21
+
+require github.com/spf13/pflag v1.0.9
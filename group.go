@@ -0,0 +1,79 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"flag"
+	"fmt"
+)
+
+type flagGroup struct {
+	title string
+	flags []*flag.Flag
+}
+
+// Group puts the named flags into a group with the given title. Usage
+// output and generated configuration files list ungrouped flags first,
+// followed by each group under its title, in the order in which groups
+// were defined. Flags in a group are listed in the given order; a flag
+// belongs to at most one group. Calling Group again with the same title
+// adds flags to the existing group.
+func Group(title string, names ...string) {
+	defaultSet.Group(title, names...)
+}
+
+// Group puts the named flags into a group with the given title.
+// See the package-level Group.
+func (fs *FlagSet) Group(title string, names ...string) {
+	var g *flagGroup
+	for _, x := range fs.groups {
+		if x.title == title {
+			g = x
+		}
+	}
+	if g == nil {
+		g = &flagGroup{title: title}
+		fs.groups = append(fs.groups, g)
+	}
+	for _, name := range names {
+		f := fs.FlagSet.Lookup(name)
+		if f == nil {
+			panic(fmt.Sprintf("conflag: grouping undefined flag %s", name))
+		}
+		if fs.grouped[name] {
+			continue
+		}
+		g.flags = append(g.flags, f)
+		fs.grouped[name] = true
+	}
+}
+
+// flagGroups returns visible flags organized into groups: the untitled
+// group of ungrouped flags in lexicographical order first, followed by
+// titled groups. Empty groups are omitted.
+func (fs *FlagSet) flagGroups() []flagGroup {
+	var rest flagGroup
+	fs.VisitAll(func(f *flag.Flag) {
+		if !fs.grouped[f.Name] && !fs.hidden[f.Name] {
+			rest.flags = append(rest.flags, f)
+		}
+	})
+	var result []flagGroup
+	if len(rest.flags) > 0 {
+		result = append(result, rest)
+	}
+	for _, g := range fs.groups {
+		v := flagGroup{title: g.title}
+		for _, f := range g.flags {
+			if !fs.hidden[f.Name] {
+				v.flags = append(v.flags, f)
+			}
+		}
+		if len(v.flags) > 0 {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,76 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import "fmt"
+
+// MissingHomePolicy defines what happens when the user configuration file
+// can't be located because the home directory of the current user can't
+// be determined, for example, in a minimal container without $HOME and
+// without an entry in the user database.
+type MissingHomePolicy int
+
+// These constants cause parsing to behave as described if the home
+// directory can't be determined.
+const (
+	MissingHomeIgnore MissingHomePolicy = iota // Silently skip the user configuration file.
+	MissingHomeWarn                            // Print a warning and skip the user configuration file.
+	MissingHomeError                           // Report an error.
+)
+
+// SetMissingHomePolicy sets the policy for the case when the home
+// directory can't be determined. The default is MissingHomeIgnore.
+// UserConfigSkipped reports whether the user configuration file was
+// skipped.
+func SetMissingHomePolicy(policy MissingHomePolicy) {
+	defaultSet.SetMissingHomePolicy(policy)
+}
+
+// SetMissingHomePolicy sets the policy for the case when the home
+// directory can't be determined. The default is MissingHomeIgnore.
+func (fs *FlagSet) SetMissingHomePolicy(policy MissingHomePolicy) {
+	fs.root().missingHomePolicy = policy
+}
+
+// UserConfigSkipped reports whether Parse skipped the user configuration
+// file because the home directory couldn't be determined.
+func UserConfigSkipped() bool {
+	return defaultSet.UserConfigSkipped()
+}
+
+// UserConfigSkipped reports whether Parse skipped the user configuration
+// file because the home directory couldn't be determined.
+func (fs *FlagSet) UserConfigSkipped() bool {
+	return fs.root().userConfigSkipped
+}
+
+// homeError is returned when no path of the user configuration file can
+// be determined because of a missing home directory.
+type homeError struct {
+	err error
+}
+
+func (e *homeError) Error() string {
+	return fmt.Sprintf("can't locate user configuration file: %s", e.err)
+}
+
+func (e *homeError) Unwrap() error { return e.err }
+
+// missingHome records that the user configuration file was skipped and
+// handles the error according to the policy, returning nil if parsing
+// should continue.
+func (fs *FlagSet) missingHome(err *homeError) error {
+	root := fs.root()
+	root.userConfigSkipped = true
+	switch root.missingHomePolicy {
+	case MissingHomeWarn:
+		fs.logf(LevelWarn, "%s", err)
+		return nil
+	case MissingHomeIgnore:
+		fs.logf(LevelDebug, "%s", err)
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,61 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// Handler returns an HTTP handler of the default set that serves the
+// effective configuration. See FlagSet.Handler.
+func Handler() http.Handler {
+	return defaultSet.Handler()
+}
+
+// Handler returns an HTTP handler that serves the effective configuration:
+// the value, default value, and source of each flag, with values of
+// sensitive flags masked. It responds with JSON in the format written by
+// WriteJSON if the request has the "format=json" query parameter or
+// accepts application/json, and with an HTML table otherwise. Mount it
+// next to other debug handlers:
+//
+//	http.Handle("/debug/config", flag.Handler())
+//
+// The configuration may reveal details about the system, so the handler
+// should be exposed only to trusted clients.
+func (fs *FlagSet) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+			w.Header().Set("Content-Type", "application/json")
+			fs.WriteJSON(w)
+			return
+		}
+		name := fs.ProgName()
+		if name == "" {
+			name = fs.Name()
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		configTemplate.Execute(w, struct {
+			Name  string
+			Flags []jsonFlag
+		}{name, fs.jsonFlags()})
+	})
+}
+
+var configTemplate = template.Must(template.New("config").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>Configuration of {{.Name}}</title>
+</head>
+<body>
+<table>
+<tr><th>Flag</th><th>Value</th><th>Default</th><th>Source</th></tr>
+{{range .Flags}}<tr><td>-{{.Name}}</td><td>{{.Value}}</td><td>{{.Default}}</td><td>{{.Source}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,43 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import "flag"
+
+// ImportCommandLine defines in the default set the flags registered on
+// flag.CommandLine, for example, by third-party libraries, so that they
+// can also be set from configuration files and the environment. It must
+// be called after the flags are registered. See AdoptFlagSet.
+func ImportCommandLine() {
+	defaultSet.AdoptFlagSet(flag.CommandLine)
+}
+
+// ImportCommandLine defines in the flag set the flags registered on
+// flag.CommandLine. See AdoptFlagSet.
+func (fs *FlagSet) ImportCommandLine() {
+	fs.AdoptFlagSet(flag.CommandLine)
+}
+
+// AdoptFlagSet defines in the default set the flags of the standard
+// library flag set. See FlagSet.AdoptFlagSet.
+func AdoptFlagSet(from *flag.FlagSet) {
+	defaultSet.AdoptFlagSet(from)
+}
+
+// AdoptFlagSet defines in the flag set the flags of the standard library
+// flag set with their usage strings and default values. The flags share
+// values with the original ones, so setting them in this set also sets
+// the variables bound to the original flags. Flags with names already
+// defined in this set, or used as aliases, are skipped.
+func (fs *FlagSet) AdoptFlagSet(from *flag.FlagSet) {
+	from.VisitAll(func(f *flag.Flag) {
+		if fs.FlagSet.Lookup(f.Name) != nil || fs.aliases[f.Name] != "" {
+			return
+		}
+		fs.Var(f.Value, f.Name, f.Usage)
+		// The value may have been changed since the flag was defined.
+		fs.FlagSet.Lookup(f.Name).DefValue = f.DefValue
+	})
+}
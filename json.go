@@ -0,0 +1,35 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// decodeJSON returns entries of the JSON document, which must be an
+// object. Keys of nested objects are mapped to dotted flag names, and
+// each element of an array is a separate entry for the same flag.
+// Numbers are kept as written.
+func decodeJSON(filename string, data []byte) ([]entry, error) {
+	data = bytes.TrimPrefix(data, []byte(utf8BOM))
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		var serr *json.SyntaxError
+		if errors.As(err, &serr) {
+			line := bytes.Count(data[:serr.Offset], []byte("\n")) + 1
+			return nil, fmt.Errorf("%s:%d: %s", filename, line, err)
+		}
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	if d.More() {
+		return nil, fmt.Errorf("%s: unexpected data after top-level value", filename)
+	}
+	return decodeTree(filename, v)
+}
@@ -0,0 +1,86 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"fmt"
+	"sort"
+)
+
+// keyringItem identifies a secret in the OS keyring.
+type keyringItem struct {
+	service, item string
+}
+
+func (k keyringItem) String() string {
+	return "keyring:" + k.service + "/" + k.item
+}
+
+// BindKeyring makes Parse set the named flag to the secret stored in the
+// OS keyring under the given service and item names, if the flag isn't
+// set from any other source, so that tokens don't have to be stored in
+// plain text configuration files. The keyring is the macOS keychain,
+// where the item is the account name; the Windows Credential Manager,
+// where the secret is the generic credential with the target name
+// "service:item"; or, on other systems, the Secret Service, such as GNOME
+// Keyring, accessed with the secret-tool command, where the secret has
+// the attributes "service" and "username" set to the service and item.
+//
+// BindKeyring also marks the flag as sensitive. The source of the value
+// is reported as "keyring:service/item". If the secret doesn't exist or
+// the keyring isn't available, the flag is left unchanged.
+func BindKeyring(flagName, service, item string) {
+	defaultSet.BindKeyring(flagName, service, item)
+}
+
+// BindKeyring makes Parse set the named flag to the secret stored in the
+// OS keyring. See the package-level BindKeyring.
+func (fs *FlagSet) BindKeyring(flagName, service, item string) {
+	if fs.FlagSet.Lookup(flagName) == nil {
+		panic(fmt.Sprintf("conflag: binding keyring item to undefined flag %s", flagName))
+	}
+	if fs.keyring == nil {
+		fs.keyring = make(map[string]keyringItem)
+	}
+	fs.keyring[flagName] = keyringItem{service, item}
+	fs.MarkSensitive(flagName)
+}
+
+// keyringEntries returns entries for flags bound to keyring items that
+// are not set from other sources.
+func (fs *FlagSet) keyringEntries() ([]entry, error) {
+	names := make([]string, 0, len(fs.keyring))
+	for name := range fs.keyring {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var entries []entry
+	for _, name := range names {
+		k := fs.keyring[name]
+		if _, ok := fs.sources[name]; ok {
+			continue
+		}
+		if _, ok := fs.pendingRefs[name]; ok {
+			continue
+		}
+		secret, found, err := readKeyring(k.service, k.item)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", k, err)
+		}
+		if !found {
+			fs.logf(LevelDebug, "%s not found", k)
+			continue
+		}
+		fs.tracef("read %s", k)
+		entries = append(entries, entry{
+			name:     name,
+			value:    secret,
+			hasValue: true,
+			literal:  true,
+			file:     k.String(),
+		})
+	}
+	return entries, nil
+}
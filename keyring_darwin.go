@@ -0,0 +1,26 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// readKeyring returns the password of the generic keychain item with the
+// service and account names.
+func readKeyring(service, item string) (secret string, found bool, err error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", service, "-a", item, "-w").Output()
+	if err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) && ee.ExitCode() == 44 {
+			// The item could not be found in the keychain.
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return strings.TrimSuffix(string(out), "\n"), true, nil
+}
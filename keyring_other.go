@@ -0,0 +1,28 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !darwin && !windows
+
+package conflag
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// readKeyring returns the secret from the Secret Service with the
+// attributes "service" and "username" set to service and item.
+func readKeyring(service, item string) (secret string, found bool, err error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", service, "username", item).Output()
+	if err != nil {
+		var ee *exec.ExitError
+		if errors.Is(err, exec.ErrNotFound) || errors.As(err, &ee) && len(out) == 0 {
+			// No secret-tool, no keyring daemon, or no such secret.
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return strings.TrimSuffix(string(out), "\n"), true, nil
+}
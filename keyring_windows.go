@@ -0,0 +1,63 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	modadvapi32  = syscall.NewLazyDLL("advapi32.dll")
+	procCredRead = modadvapi32.NewProc("CredReadW")
+	procCredFree = modadvapi32.NewProc("CredFree")
+)
+
+// credential is the CREDENTIALW structure.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+const (
+	credTypeGeneric         = 1
+	errorNotFound           = syscall.Errno(1168)
+	errorNoSuchLogonSession = syscall.Errno(1312)
+)
+
+// readKeyring returns the blob of the generic credential with the target
+// name "service:item".
+func readKeyring(service, item string) (secret string, found bool, err error) {
+	target, err := syscall.UTF16PtrFromString(service + ":" + item)
+	if err != nil {
+		return "", false, nil
+	}
+	if err := procCredRead.Find(); err != nil {
+		return "", false, nil
+	}
+	var cred *credential
+	r, _, e := procCredRead.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		if e == errorNotFound || e == errorNoSuchLogonSession {
+			return "", false, nil
+		}
+		return "", false, e
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	if cred.CredentialBlobSize == 0 {
+		return "", true, nil
+	}
+	return string(unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)), true, nil
+}
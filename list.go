@@ -0,0 +1,240 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listValue is implemented by flag values holding lists, which are
+// cleared when set from a new source, unless the list mode is ListAppend,
+// so that lists from configuration files or the command line replace
+// lists from sources loaded before them instead of appending to them.
+type listValue interface {
+	resetList()
+}
+
+// ListMode defines how a list from a source combines with lists from
+// sources loaded before it.
+type ListMode int
+
+// These constants cause list flags to behave as described when they are
+// set from several sources.
+const (
+	ListReplace ListMode = iota // Replace the list from sources loaded before.
+	ListAppend                  // Append to the list from sources loaded before.
+)
+
+// SetListMode sets the mode of the named list flag, such as one defined
+// with IntSlice, for combining lists from several sources, such as the
+// global and user configuration files and the command line. The default
+// is ListReplace. Elements given in the same source always accumulate,
+// and the first source replaces the default value in both modes.
+func SetListMode(name string, mode ListMode) {
+	defaultSet.SetListMode(name, mode)
+}
+
+// SetListMode sets the mode of the named list flag for combining lists
+// from several sources. See the package-level SetListMode.
+func (fs *FlagSet) SetListMode(name string, mode ListMode) {
+	fs.lookupList(name)
+	if fs.listModes == nil {
+		fs.listModes = make(map[string]ListMode)
+	}
+	fs.listModes[name] = mode
+}
+
+// SetListDelimiter sets the delimiter of elements in values of the named
+// list flag from all sources, such as " " or "\n" for lists of elements
+// separated by spaces or written on separate lines of multiline values.
+// Whitespace around elements and empty elements are ignored. The default
+// delimiter is a comma.
+func SetListDelimiter(name, delim string) {
+	defaultSet.SetListDelimiter(name, delim)
+}
+
+// SetListDelimiter sets the delimiter of elements in values of the named
+// list flag. See the package-level SetListDelimiter.
+func (fs *FlagSet) SetListDelimiter(name, delim string) {
+	fs.lookupList(name)
+	if delim == "" {
+		panic("conflag: empty list delimiter")
+	}
+	if fs.listDelims == nil {
+		fs.listDelims = make(map[string]string)
+	}
+	fs.listDelims[name] = delim
+}
+
+// lookupList panics if the named flag isn't a defined list flag.
+func (fs *FlagSet) lookupList(name string) {
+	f := fs.FlagSet.Lookup(name)
+	if f == nil {
+		panic(fmt.Sprintf("conflag: setting list options of undefined flag %s", name))
+	}
+	if _, ok := f.Value.(listValue); !ok {
+		panic(fmt.Sprintf("conflag: flag %s is not a list", name))
+	}
+}
+
+// listElems returns the value of the named list flag with elements
+// separated by the delimiter set with SetListDelimiter converted to the
+// comma-separated form accepted by list values.
+func (fs *FlagSet) listElems(name, value string) string {
+	delim, ok := fs.listDelims[name]
+	if !ok {
+		return value
+	}
+	var elems []string
+	for _, e := range strings.Split(value, delim) {
+		if e = strings.TrimSpace(e); e != "" {
+			elems = append(elems, e)
+		}
+	}
+	return strings.Join(elems, ",")
+}
+
+// sliceValue is a flag value holding a list of elements parsed from
+// comma-separated values.
+type sliceValue[T int | time.Duration] []T
+
+func (s *sliceValue[T]) Set(v string) error {
+	if strings.TrimSpace(v) == "" {
+		return nil
+	}
+	for _, part := range strings.Split(v, ",") {
+		e, err := parseElem[T](strings.TrimSpace(part))
+		if err != nil {
+			return err
+		}
+		*s = append(*s, e)
+	}
+	return nil
+}
+
+func (s *sliceValue[T]) Get() interface{} { return []T(*s) }
+
+func (s *sliceValue[T]) String() string {
+	if s == nil {
+		return ""
+	}
+	parts := make([]string, len(*s))
+	for i, e := range *s {
+		parts[i] = formatElem(e)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (s *sliceValue[T]) typeName() string {
+	var zero T
+	switch any(zero).(type) {
+	case time.Duration:
+		return "durations"
+	}
+	return "ints"
+}
+
+func (s *sliceValue[T]) resetList() { *s = nil }
+
+// parseElem parses an element of a list.
+func parseElem[T int | time.Duration](s string) (T, error) {
+	var zero T
+	switch any(zero).(type) {
+	case time.Duration:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return zero, fmt.Errorf("invalid duration %q", s)
+		}
+		return T(d), nil
+	}
+	n, err := strconv.ParseInt(s, 0, strconv.IntSize)
+	if errors.Is(err, strconv.ErrRange) {
+		return zero, fmt.Errorf("%s is out of range", s)
+	}
+	if err != nil {
+		return zero, fmt.Errorf("invalid number %q", s)
+	}
+	return T(n), nil
+}
+
+// formatElem formats an element of a list.
+func formatElem[T int | time.Duration](e T) string {
+	if d, ok := any(e).(time.Duration); ok {
+		return d.String()
+	}
+	return strconv.Itoa(int(e))
+}
+
+// IntSliceVar defines a flag holding a list of ints with specified name,
+// default value, and usage string. The argument p points to a slice
+// variable in which to store the value of the flag. The value is a
+// comma-separated list, such as "8080,8443", and repeating the flag or
+// the configuration file key, or giving an array in formats that support
+// them, adds elements to the list. A list from a configuration file, the
+// environment or the command line replaces the list from sources loaded
+// before it. An empty value sets an empty list. The delimiter and the
+// mode of combining lists can be changed with SetListDelimiter and
+// SetListMode.
+func IntSliceVar(p *[]int, name string, value []int, usage string) {
+	defaultSet.IntSliceVar(p, name, value, usage)
+}
+
+// IntSliceVar defines a flag holding a list of ints. See the
+// package-level IntSliceVar.
+func (fs *FlagSet) IntSliceVar(p *[]int, name string, value []int, usage string) {
+	*p = append([]int(nil), value...)
+	fs.Var((*sliceValue[int])(p), name, usage)
+}
+
+// IntSlice defines a flag holding a list of ints with specified name,
+// default value, and usage string. The return value is the address of a
+// slice variable that stores the value of the flag. See IntSliceVar.
+func IntSlice(name string, value []int, usage string) *[]int {
+	return defaultSet.IntSlice(name, value, usage)
+}
+
+// IntSlice defines a flag holding a list of ints. See the package-level
+// IntSlice.
+func (fs *FlagSet) IntSlice(name string, value []int, usage string) *[]int {
+	p := new([]int)
+	fs.IntSliceVar(p, name, value, usage)
+	return p
+}
+
+// DurationSliceVar defines a flag holding a list of time.Duration values,
+// such as a retry schedule, with specified name, default value, and usage
+// string. The argument p points to a slice variable in which to store the
+// value of the flag. The value is a comma-separated list of durations
+// accepted by time.ParseDuration, such as "1s,5s,30s". See IntSliceVar.
+func DurationSliceVar(p *[]time.Duration, name string, value []time.Duration, usage string) {
+	defaultSet.DurationSliceVar(p, name, value, usage)
+}
+
+// DurationSliceVar defines a flag holding a list of time.Duration values.
+// See the package-level DurationSliceVar.
+func (fs *FlagSet) DurationSliceVar(p *[]time.Duration, name string, value []time.Duration, usage string) {
+	*p = append([]time.Duration(nil), value...)
+	fs.Var((*sliceValue[time.Duration])(p), name, usage)
+}
+
+// DurationSlice defines a flag holding a list of time.Duration values with
+// specified name, default value, and usage string. The return value is
+// the address of a slice variable that stores the value of the flag. See
+// DurationSliceVar.
+func DurationSlice(name string, value []time.Duration, usage string) *[]time.Duration {
+	return defaultSet.DurationSlice(name, value, usage)
+}
+
+// DurationSlice defines a flag holding a list of time.Duration values.
+// See the package-level DurationSlice.
+func (fs *FlagSet) DurationSlice(name string, value []time.Duration, usage string) *[]time.Duration {
+	p := new([]time.Duration)
+	fs.DurationSliceVar(p, name, value, usage)
+	return p
+}
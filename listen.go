@@ -0,0 +1,109 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ListenAddr is an address for a server to listen on: a TCP address, such
+// as "localhost:8080", "[::1]:8080" or ":8080", or a Unix socket path
+// given as "unix:///run/mycmd.sock". The zero value is an empty address.
+// A pointer to ListenAddr is a flag value, which can be defined with
+// ListenAddrVar or Var.
+type ListenAddr struct {
+	network string
+	address string
+}
+
+// ParseListenAddr parses the listen address. The port of a TCP address
+// may be a number or a service name, such as "http". An empty string is
+// parsed as the empty address.
+func ParseListenAddr(s string) (ListenAddr, error) {
+	if s == "" {
+		return ListenAddr{}, nil
+	}
+	if path, ok := strings.CutPrefix(s, "unix://"); ok {
+		if path == "" {
+			return ListenAddr{}, errors.New("missing socket path")
+		}
+		return ListenAddr{"unix", path}, nil
+	}
+	if strings.Contains(s, "://") {
+		return ListenAddr{}, fmt.Errorf("unsupported address %s, use host:port or unix:///path", s)
+	}
+	_, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return ListenAddr{}, fmt.Errorf("bad address %s, use host:port or unix:///path", s)
+	}
+	if _, err := net.LookupPort("tcp", port); err != nil {
+		return ListenAddr{}, fmt.Errorf("bad port %q", port)
+	}
+	return ListenAddr{"tcp", s}, nil
+}
+
+// Network returns the network of the address, "tcp" or "unix", or an
+// empty string for the empty address, as expected by net.Listen.
+func (a ListenAddr) Network() string { return a.network }
+
+// Address returns the host and port of a TCP address or the path of a
+// Unix socket, as expected by net.Listen.
+func (a ListenAddr) Address() string { return a.address }
+
+// String returns the address in the form accepted by ParseListenAddr.
+func (a ListenAddr) String() string {
+	if a.network == "unix" {
+		return "unix://" + a.address
+	}
+	return a.address
+}
+
+// Listen announces on the address with net.Listen.
+func (a ListenAddr) Listen() (net.Listener, error) {
+	if a.network == "" {
+		return nil, errors.New("conflag: empty listen address")
+	}
+	return net.Listen(a.network, a.address)
+}
+
+// Set sets the address from its string form, implementing flag.Value.
+func (a *ListenAddr) Set(s string) error {
+	v, err := ParseListenAddr(s)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// Get returns the address, implementing flag.Getter.
+func (a *ListenAddr) Get() interface{} { return *a }
+
+func (a *ListenAddr) typeName() string { return "address" }
+
+// ListenAddrVar defines a listen address flag with specified name, default
+// value, and usage string. The argument p points to a ListenAddr variable
+// in which to store the value of the flag. The default value must be a
+// valid address; ListenAddrVar panics otherwise.
+//
+//	var addr conflag.ListenAddr
+//	conflag.ListenAddrVar(&addr, "listen", ":8080", "listen on `address` (host:port or unix:///path)")
+//	conflag.Parse()
+//	l, err := addr.Listen()
+func ListenAddrVar(p *ListenAddr, name string, value string, usage string) {
+	defaultSet.ListenAddrVar(p, name, value, usage)
+}
+
+// ListenAddrVar defines a listen address flag. See the package-level
+// ListenAddrVar.
+func (fs *FlagSet) ListenAddrVar(p *ListenAddr, name string, value string, usage string) {
+	if err := p.Set(value); err != nil {
+		panic(fmt.Sprintf("conflag: invalid default value %q for flag %s: %v", value, name, err))
+	}
+	fs.Var(p, name, usage)
+}
@@ -0,0 +1,49 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// Levels of messages passed to the logger set with SetLogger.
+const (
+	LevelDebug = "debug" // Informational, such as missing optional files.
+	LevelWarn  = "warn"  // Non-fatal problems, such as unknown keys or deprecated flags.
+)
+
+// SetLogger sets the function receiving messages about non-fatal
+// conditions, such as unknown keys, duplicate keys, deprecated flags, or
+// missing configuration files, instead of writing warnings to the output
+// of the flag set. The level is LevelDebug or LevelWarn. Debug messages
+// are discarded if no logger is set. Errors that stop parsing are still
+// handled according to the error handling property of the flag set.
+func SetLogger(logger func(level, msg string)) {
+	defaultSet.SetLogger(logger)
+}
+
+// SetLogger sets the function receiving messages about non-fatal
+// conditions. See the package-level SetLogger.
+func (fs *FlagSet) SetLogger(logger func(level, msg string)) {
+	fs.root().logger = logger
+}
+
+// logf passes the message to the logger, or prints warnings to the output
+// if no logger is set, or emits it to the logger set with SetSlogLogger.
+func (fs *FlagSet) logf(level, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if logger := fs.root().logger; logger != nil {
+		logger(level, msg)
+	} else if fs.root().slogger != nil {
+		l := slog.LevelDebug
+		if level == LevelWarn {
+			l = slog.LevelWarn
+		}
+		fs.event(l, msg)
+	} else if level == LevelWarn {
+		fmt.Fprintln(fs.Output(), msg)
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteManPage writes to w a manual page in the roff format, for section 1,
+// documenting visible flags with their usage and default values under
+// their groups, commands, and configuration files.
+func WriteManPage(w io.Writer) error {
+	return defaultSet.WriteManPage(w)
+}
+
+// WriteManPage writes to w a manual page documenting the flag set.
+// See the package-level WriteManPage.
+func (fs *FlagSet) WriteManPage(w io.Writer) error {
+	d := fs.usageData()
+	name := d.docName()
+	var b strings.Builder
+	fmt.Fprintf(&b, ".TH %s 1\n", roffEscape(strings.ToUpper(name)))
+	fmt.Fprintf(&b, ".SH NAME\n%s\n", roffEscape(name))
+	fmt.Fprintf(&b, ".SH SYNOPSIS\n.B %s\n[\\fIflags\\fR]", roffEscape(name))
+	if len(d.Commands) > 0 {
+		b.WriteString(" [\\fIcommand\\fR]")
+	}
+	b.WriteString("\n.SH OPTIONS\n")
+	for _, g := range d.Groups {
+		if g.Title != "" {
+			fmt.Fprintf(&b, ".SS %s\n", roffEscape(g.Title))
+		}
+		for _, f := range g.Flags {
+			b.WriteString(".TP\n\\fB")
+			for i, n := range append([]string{f.Name}, f.Aliases...) {
+				if i > 0 {
+					b.WriteString("\\fR, \\fB")
+				}
+				b.WriteString(strings.ReplaceAll(roffEscape("-"+n), "-", `\-`))
+			}
+			b.WriteString("\\fR")
+			if f.ValueName != "" {
+				fmt.Fprintf(&b, " \\fI%s\\fR", roffEscape(f.ValueName))
+			}
+			b.WriteString("\n" + roffEscape(f.Usage))
+			if f.HasDefault {
+				fmt.Fprintf(&b, " (default %s)", roffEscape(f.Default))
+			}
+			b.WriteString("\n")
+		}
+	}
+	if len(d.Commands) > 0 {
+		b.WriteString(".SH COMMANDS\n")
+		for _, c := range d.Commands {
+			fmt.Fprintf(&b, ".TP\n.B %s\n", roffEscape(c))
+		}
+	}
+	if files := fs.docConfigFiles(); len(files) > 0 {
+		b.WriteString(".SH FILES\n")
+		for _, f := range files {
+			fmt.Fprintf(&b, ".TP\n.I %s\n", roffEscape(f))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteMarkdown writes to w a Markdown document describing visible flags
+// with their usage and default values under their groups, commands, and
+// configuration files.
+func WriteMarkdown(w io.Writer) error {
+	return defaultSet.WriteMarkdown(w)
+}
+
+// WriteMarkdown writes to w a Markdown document describing the flag set.
+// See the package-level WriteMarkdown.
+func (fs *FlagSet) WriteMarkdown(w io.Writer) error {
+	d := fs.usageData()
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n## Flags\n", d.docName())
+	for _, g := range d.Groups {
+		if g.Title != "" {
+			fmt.Fprintf(&b, "\n### %s\n", g.Title)
+		}
+		b.WriteString("\n")
+		for _, f := range g.Flags {
+			b.WriteString("- ")
+			for i, n := range append([]string{f.Name}, f.Aliases...) {
+				if i > 0 {
+					b.WriteString(", ")
+				}
+				b.WriteString("`-" + n)
+				if f.ValueName != "" {
+					b.WriteString(" " + f.ValueName)
+				}
+				b.WriteString("`")
+			}
+			if f.Usage != "" {
+				b.WriteString(": " + strings.ReplaceAll(f.Usage, "\n", "\n  "))
+			}
+			if f.HasDefault {
+				fmt.Fprintf(&b, " (default `%s`)", f.Default)
+			}
+			b.WriteString("\n")
+		}
+	}
+	if len(d.Commands) > 0 {
+		b.WriteString("\n## Commands\n\n")
+		for _, c := range d.Commands {
+			fmt.Fprintf(&b, "- `%s`\n", c)
+		}
+	}
+	if files := fs.docConfigFiles(); len(files) > 0 {
+		b.WriteString("\n## Configuration files\n\n")
+		for _, f := range files {
+			fmt.Fprintf(&b, "- `%s`\n", f)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// docConfigFiles returns the paths of configuration files for
+// documentation in the order of loading. Unlike the paths in UsageData,
+// they are not expanded, except for the program name in patterns, so
+// that the documentation doesn't depend on the home directory and the
+// environment of the user who generates it:
+//
+//	{sysconfdir}/mycmd
+//	~/.mycmd
+func (fs *FlagSet) docConfigFiles() []string {
+	root := fs.root()
+	if root.progName == "" {
+		return nil
+	}
+	patterns := func(layer Layer) (paths []string) {
+		for _, pattern := range fs.layerPatterns(layer) {
+			paths = append(paths, strings.ReplaceAll(pattern, "{prog}", root.progName))
+		}
+		return paths
+	}
+	var paths []string
+	for _, layer := range root.layers() {
+		switch layer {
+		case LayerGlobal:
+			if root.searchPaths == nil && !root.noGlobalConfig {
+				paths = append(paths, patterns(layer)...)
+			}
+		case LayerUser:
+			if root.searchPaths != nil {
+				paths = append(paths, root.searchPaths...)
+			} else if !root.noUserConfig {
+				paths = append(paths, patterns(layer)...)
+			}
+		case LayerDotEnv:
+			if root.dotEnvFile != "" {
+				paths = append(paths, root.dotEnvFile)
+			}
+		}
+	}
+	return paths
+}
+
+// docName returns the name of the program or command for documentation.
+func (d *UsageData) docName() string {
+	name := d.ProgName
+	if name == "" {
+		name = d.Name
+	}
+	if d.Command != "" {
+		name += " " + d.Command
+	}
+	return name
+}
+
+// roffEscape escapes backslashes in s and control characters at the
+// beginning of its lines.
+func roffEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
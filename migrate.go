@@ -0,0 +1,67 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import "fmt"
+
+// MigrateKey makes the obsolete configuration file key old set the flag
+// named newName, which must already be defined, so that configuration
+// files written for previous releases keep working after a flag is
+// renamed. Unlike DeprecateAlias, the old name is accepted only in
+// configuration files, not on the command line. Use of the old key is
+// reported to the logger at LevelDebug, or as a warning if
+// EnableMigrationWarnings was called.
+func MigrateKey(old, newName string) {
+	defaultSet.MigrateKey(old, newName)
+}
+
+// MigrateKey makes the obsolete configuration file key old set the flag
+// named newName. See the package-level MigrateKey.
+func (fs *FlagSet) MigrateKey(old, newName string) {
+	if fs.FlagSet.Lookup(newName) == nil {
+		panic(fmt.Sprintf("conflag: migrating key %s to undefined flag %s", old, newName))
+	}
+	if fs.Lookup(old) != nil {
+		panic(fmt.Sprintf("conflag: migrating key %s, which is a defined flag", old))
+	}
+	fs.migrations[old] = newName
+}
+
+// EnableMigrationWarnings makes the use of keys migrated with MigrateKey
+// print a warning asking to rename the key.
+func EnableMigrationWarnings() {
+	defaultSet.EnableMigrationWarnings()
+}
+
+// EnableMigrationWarnings makes the use of migrated keys print a warning.
+func (fs *FlagSet) EnableMigrationWarnings() {
+	fs.root().warnMigrated = true
+}
+
+// migratedName returns the name of the flag to which the obsolete key
+// was migrated.
+func (fs *FlagSet) migratedName(key string) (string, bool) {
+	if newName, ok := fs.migrations[key]; ok {
+		return newName, true
+	}
+	if normalize := fs.root().normalize; normalize != nil {
+		nkey := normalize(key)
+		for old, newName := range fs.migrations {
+			if normalize(old) == nkey {
+				return newName, true
+			}
+		}
+	}
+	return "", false
+}
+
+// logMigrated reports that the entry used the obsolete key.
+func (fs *FlagSet) logMigrated(e entry, newName string) {
+	level := LevelDebug
+	if fs.root().warnMigrated {
+		level = LevelWarn
+	}
+	fs.logf(level, "%s", e.errorf("key %s is obsolete, use %s instead", e.name, newName))
+}
@@ -0,0 +1,156 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"flag"
+	"strings"
+	"time"
+)
+
+// A Namespace defines flags with names prefixed by the namespace name and
+// a dot, so that independently developed packages can define flags without
+// collisions. For example, a database package may define "max-conns" in
+// the namespace "db":
+//
+//	var ns = flag.NewNamespace("db")
+//	var maxConns = ns.Int("max-conns", 10, "maximum number of connections")
+//
+// The flag is named "db.max-conns" on the command line and in
+// configuration files. As with any prefix, entries following a "[db]" line
+// in configuration files are keys of flags in the namespace without the
+// prefix:
+//
+//	[db]
+//	max-conns = 20
+type Namespace struct {
+	fs     *FlagSet
+	prefix string
+}
+
+// NewNamespace returns a namespace with the given name, such as "db",
+// for defining flags in the default set. A trailing dot in the name
+// is ignored.
+func NewNamespace(name string) *Namespace {
+	return defaultSet.NewNamespace(name)
+}
+
+// NewNamespace returns a namespace with the given name for defining flags
+// in the set.
+func (fs *FlagSet) NewNamespace(name string) *Namespace {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" || strings.ContainsAny(name, " []=") {
+		panic("conflag: bad namespace name: " + name)
+	}
+	return &Namespace{fs: fs, prefix: name + "."}
+}
+
+// Name returns the full name of the flag with the given name in the
+// namespace.
+func (ns *Namespace) Name(name string) string {
+	return ns.prefix + name
+}
+
+// Lookup returns the Flag structure of the named flag in the namespace,
+// returning nil if none exists.
+func (ns *Namespace) Lookup(name string) *flag.Flag {
+	return ns.fs.Lookup(ns.prefix + name)
+}
+
+// Var defines a flag with the specified name and usage string in the
+// namespace. See flag.Var.
+func (ns *Namespace) Var(value flag.Value, name string, usage string) {
+	ns.fs.Var(value, ns.prefix+name, usage)
+}
+
+// BoolVar defines a bool flag in the namespace.
+func (ns *Namespace) BoolVar(p *bool, name string, value bool, usage string) {
+	ns.fs.BoolVar(p, ns.prefix+name, value, usage)
+}
+
+// Bool defines a bool flag in the namespace.
+func (ns *Namespace) Bool(name string, value bool, usage string) *bool {
+	return ns.fs.Bool(ns.prefix+name, value, usage)
+}
+
+// IntVar defines an int flag in the namespace.
+func (ns *Namespace) IntVar(p *int, name string, value int, usage string) {
+	ns.fs.IntVar(p, ns.prefix+name, value, usage)
+}
+
+// Int defines an int flag in the namespace.
+func (ns *Namespace) Int(name string, value int, usage string) *int {
+	return ns.fs.Int(ns.prefix+name, value, usage)
+}
+
+// Int64Var defines an int64 flag in the namespace.
+func (ns *Namespace) Int64Var(p *int64, name string, value int64, usage string) {
+	ns.fs.Int64Var(p, ns.prefix+name, value, usage)
+}
+
+// Int64 defines an int64 flag in the namespace.
+func (ns *Namespace) Int64(name string, value int64, usage string) *int64 {
+	return ns.fs.Int64(ns.prefix+name, value, usage)
+}
+
+// UintVar defines a uint flag in the namespace.
+func (ns *Namespace) UintVar(p *uint, name string, value uint, usage string) {
+	ns.fs.UintVar(p, ns.prefix+name, value, usage)
+}
+
+// Uint defines a uint flag in the namespace.
+func (ns *Namespace) Uint(name string, value uint, usage string) *uint {
+	return ns.fs.Uint(ns.prefix+name, value, usage)
+}
+
+// Uint64Var defines a uint64 flag in the namespace.
+func (ns *Namespace) Uint64Var(p *uint64, name string, value uint64, usage string) {
+	ns.fs.Uint64Var(p, ns.prefix+name, value, usage)
+}
+
+// Uint64 defines a uint64 flag in the namespace.
+func (ns *Namespace) Uint64(name string, value uint64, usage string) *uint64 {
+	return ns.fs.Uint64(ns.prefix+name, value, usage)
+}
+
+// StringVar defines a string flag in the namespace.
+func (ns *Namespace) StringVar(p *string, name string, value string, usage string) {
+	ns.fs.StringVar(p, ns.prefix+name, value, usage)
+}
+
+// String defines a string flag in the namespace.
+func (ns *Namespace) String(name string, value string, usage string) *string {
+	return ns.fs.String(ns.prefix+name, value, usage)
+}
+
+// Float64Var defines a float64 flag in the namespace.
+func (ns *Namespace) Float64Var(p *float64, name string, value float64, usage string) {
+	ns.fs.Float64Var(p, ns.prefix+name, value, usage)
+}
+
+// Float64 defines a float64 flag in the namespace.
+func (ns *Namespace) Float64(name string, value float64, usage string) *float64 {
+	return ns.fs.Float64(ns.prefix+name, value, usage)
+}
+
+// DurationVar defines a time.Duration flag in the namespace.
+func (ns *Namespace) DurationVar(p *time.Duration, name string, value time.Duration, usage string) {
+	ns.fs.DurationVar(p, ns.prefix+name, value, usage)
+}
+
+// Duration defines a time.Duration flag in the namespace.
+func (ns *Namespace) Duration(name string, value time.Duration, usage string) *time.Duration {
+	return ns.fs.Duration(ns.prefix+name, value, usage)
+}
+
+// PathVar defines a file path flag in the namespace. See PathVar.
+func (ns *Namespace) PathVar(p *string, name string, value string, usage string) {
+	ns.fs.PathVar(p, ns.prefix+name, value, usage)
+}
+
+// Path defines a file path flag in the namespace. See Path.
+func (ns *Namespace) Path(name string, value string, usage string) *string {
+	return ns.fs.Path(ns.prefix+name, value, usage)
+}
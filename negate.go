@@ -0,0 +1,60 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"flag"
+	"strconv"
+	"strings"
+)
+
+// EnableNegationOnCommandLine makes every boolean flag, such as -verbose,
+// also accept a negated form, -no-verbose, on the command line. Negated
+// forms are always accepted in configuration files.
+func EnableNegationOnCommandLine() {
+	defaultSet.EnableNegationOnCommandLine()
+}
+
+// EnableNegationOnCommandLine makes boolean flags also accept a negated
+// form on the command line.
+func (fs *FlagSet) EnableNegationOnCommandLine() {
+	fs.root().negation = true
+}
+
+// lookupNegated returns the boolean flag turned off by the negated name,
+// such as "no-verbose", or nil if the name isn't a negation.
+func (fs *FlagSet) lookupNegated(name string) *flag.Flag {
+	if normalize := fs.root().normalize; normalize != nil {
+		name = normalize(name)
+	}
+	rest, ok := strings.CutPrefix(name, "no-")
+	if !ok {
+		// Negated name with a prefix, such as "db.no-verbose".
+		i := strings.LastIndex(name, ".")
+		if i < 0 || !strings.HasPrefix(name[i+1:], "no-") {
+			return nil
+		}
+		rest = name[:i+1] + name[i+4:]
+	}
+	if f := fs.Lookup(fs.normalizeKey(rest)); f != nil && isBoolFlag(f) {
+		return f
+	}
+	return nil
+}
+
+// negatedValue sets the inverse of a boolean value.
+type negatedValue struct {
+	*sourceValue
+}
+
+func (v negatedValue) String() string { return "" }
+
+func (v negatedValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	return v.sourceValue.Set(strconv.FormatBool(!b))
+}
@@ -0,0 +1,70 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"flag"
+	"strings"
+	"unicode"
+)
+
+// SetNormalizeFunc sets the function used to match keys in configuration
+// files to flag names: a key refers to a flag if both normalize to the
+// same string. By default, keys must match flag names exactly. Passing
+// NormalizeName makes "max_conns", "MaxConns" and "max-conns" all refer
+// to the flag "max-conns".
+func SetNormalizeFunc(fn func(name string) string) {
+	defaultSet.SetNormalizeFunc(fn)
+}
+
+// SetNormalizeFunc sets the function used to match keys in configuration
+// files to flag names. See the package-level SetNormalizeFunc.
+func (fs *FlagSet) SetNormalizeFunc(fn func(name string) string) {
+	fs.root().normalize = fn
+}
+
+// NormalizeName converts name to lower case, replaces underscores with
+// dashes, and separates words in camel case names with dashes.
+func NormalizeName(name string) string {
+	var b strings.Builder
+	rs := []rune(name)
+	for i, r := range rs {
+		switch {
+		case r == '_':
+			b.WriteByte('-')
+		case unicode.IsUpper(r):
+			if i > 0 && (unicode.IsLower(rs[i-1]) || unicode.IsDigit(rs[i-1]) ||
+				unicode.IsUpper(rs[i-1]) && i+1 < len(rs) && unicode.IsLower(rs[i+1])) {
+				b.WriteByte('-')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// normalizeKey returns the name of the defined flag or alias to which
+// the configuration file key refers, or the key itself if there's none.
+func (fs *FlagSet) normalizeKey(key string) string {
+	normalize := fs.root().normalize
+	if normalize == nil || fs.FlagSet.Lookup(key) != nil || fs.aliases[key] != "" {
+		return key
+	}
+	nkey := normalize(key)
+	name := key
+	fs.VisitAll(func(f *flag.Flag) {
+		if normalize(f.Name) == nkey {
+			name = f.Name
+		}
+	})
+	for alias := range fs.aliases {
+		if normalize(alias) == nkey {
+			name = alias
+		}
+	}
+	return name
+}
@@ -0,0 +1,122 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// homeDirOverride is the home directory set with SetHomeDir.
+var homeDirOverride string
+
+// SetHomeDir sets the directory used as the home directory of the current
+// user for locating the user configuration file and expanding "~" in
+// paths, which is useful in tests and chroots. By default, the home
+// directory is determined by os.UserHomeDir, which respects $HOME, or
+// from the user database if $HOME is not set. The setting applies to all
+// flag sets. Passing an empty path restores the default.
+func SetHomeDir(path string) {
+	homeDirOverride = path
+}
+
+// homeDir returns the home directory of the current user.
+func homeDir() (string, error) {
+	if homeDirOverride != "" {
+		return homeDirOverride, nil
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return home, nil
+	}
+	u, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return u.HomeDir, nil
+}
+
+// ExpandHome replaces the leading "~" or "~user" path element with the
+// home directory of the current or the named user. Other paths are
+// returned unchanged.
+func ExpandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~") {
+		return path, nil
+	}
+	name, rest, _ := strings.Cut(path[1:], "/")
+	var home string
+	if name == "" {
+		h, err := homeDir()
+		if err != nil {
+			return "", err
+		}
+		home = h
+	} else {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return "", err
+		}
+		home = u.HomeDir
+	}
+	return filepath.Join(home, rest), nil
+}
+
+// pathValue is a string flag value with home directory expansion.
+type pathValue string
+
+func newPathValue(val string, p *string) *pathValue {
+	if v, err := ExpandHome(val); err == nil {
+		val = v
+	}
+	*p = val
+	return (*pathValue)(p)
+}
+
+func (p *pathValue) Set(s string) error {
+	v, err := ExpandHome(s)
+	if err != nil {
+		return err
+	}
+	*p = pathValue(v)
+	return nil
+}
+
+func (p *pathValue) Get() interface{} { return string(*p) }
+
+func (p *pathValue) String() string { return string(*p) }
+
+func (p *pathValue) typeName() string { return "path" }
+
+// PathVar defines a file path flag with specified name, default value, and
+// usage string. The argument p points to a string variable in which to
+// store the value of the flag. A leading "~" in the value, whether it comes
+// from the default, a configuration file or the command line, is replaced
+// with the home directory.
+func PathVar(p *string, name string, value string, usage string) {
+	defaultSet.PathVar(p, name, value, usage)
+}
+
+// PathVar defines a file path flag with specified name, default value, and
+// usage string. See the package-level PathVar.
+func (fs *FlagSet) PathVar(p *string, name string, value string, usage string) {
+	fs.Var(newPathValue(value, p), name, usage)
+}
+
+// Path defines a file path flag with specified name, default value, and
+// usage string. The return value is the address of a string variable that
+// stores the value of the flag. A leading "~" in the value is replaced
+// with the home directory.
+func Path(name string, value string, usage string) *string {
+	return defaultSet.Path(name, value, usage)
+}
+
+// Path defines a file path flag with specified name, default value, and
+// usage string. See the package-level Path.
+func (fs *FlagSet) Path(name string, value string, usage string) *string {
+	p := new(string)
+	fs.PathVar(p, name, value, usage)
+	return p
+}
@@ -0,0 +1,266 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+)
+
+// validatedValue is implemented by flag values that are checked after
+// all sources are parsed, so that a value overridden by a later source
+// isn't reported.
+type validatedValue interface {
+	validate() error
+}
+
+// pathCheck checks a file system path.
+type pathCheck interface {
+	check(path string) error
+	typeName() string
+}
+
+// checkedPathValue is a path flag value with home directory expansion,
+// which is checked by C.
+type checkedPathValue[C pathCheck] string
+
+func newCheckedPathValue[C pathCheck](val string, p *string) *checkedPathValue[C] {
+	if v, err := ExpandHome(val); err == nil {
+		val = v
+	}
+	*p = val
+	return (*checkedPathValue[C])(p)
+}
+
+func (p *checkedPathValue[C]) Set(s string) error {
+	v, err := ExpandHome(s)
+	if err != nil {
+		return err
+	}
+	*p = checkedPathValue[C](v)
+	return nil
+}
+
+func (p *checkedPathValue[C]) Get() interface{} { return string(*p) }
+
+func (p *checkedPathValue[C]) String() string {
+	if p == nil {
+		return ""
+	}
+	return string(*p)
+}
+
+func (p *checkedPathValue[C]) typeName() string {
+	var c C
+	return c.typeName()
+}
+
+func (p *checkedPathValue[C]) validate() error {
+	if *p == "" {
+		return nil
+	}
+	var c C
+	return c.check(string(*p))
+}
+
+// pathError returns the error without the path, which is reported, or
+// masked if the flag is sensitive, by checkValues.
+func pathError(err error) error {
+	var perr *iofs.PathError
+	if errors.As(err, &perr) {
+		return perr.Err
+	}
+	return err
+}
+
+// existingFile checks that the path names an existing file.
+type existingFile struct{}
+
+func (existingFile) typeName() string { return "file" }
+
+func (existingFile) check(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return pathError(err)
+	}
+	if fi.IsDir() {
+		return errors.New("is a directory")
+	}
+	return nil
+}
+
+// existingDir checks that the path names an existing directory.
+type existingDir struct{}
+
+func (existingDir) typeName() string { return "dir" }
+
+func (existingDir) check(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return pathError(err)
+	}
+	if !fi.IsDir() {
+		return errors.New("is not a directory")
+	}
+	return nil
+}
+
+// creatableFile checks that the path names a writable file or a file
+// that can be created.
+type creatableFile struct{}
+
+func (creatableFile) typeName() string { return "file" }
+
+func (creatableFile) check(path string) error {
+	fi, err := os.Stat(path)
+	if err == nil {
+		if fi.IsDir() {
+			return errors.New("is a directory")
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return pathError(err)
+		}
+		return f.Close()
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return pathError(err)
+	}
+	dir := filepath.Dir(path)
+	if err := (existingDir{}).check(dir); err != nil {
+		return fmt.Errorf("parent directory: %v", err)
+	}
+	// Creating a file is the only portable way to check that the
+	// directory is writable.
+	f, err := os.CreateTemp(dir, ".conflag-*")
+	if err != nil {
+		return fmt.Errorf("can't create files in parent directory: %v", pathError(err))
+	}
+	f.Close()
+	return pathError(os.Remove(f.Name()))
+}
+
+// checkValues checks values of flags that are validated after parsing.
+func (fs *FlagSet) checkValues() error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		v, ok := f.Value.(validatedValue)
+		if !ok || err != nil {
+			return
+		}
+		if verr := v.validate(); verr != nil {
+			// Mask the value of a sensitive flag, which may be logged.
+			value := fs.displayValue(f)
+			switch source := fs.Source(f.Name); source {
+			case SourceDefault:
+				err = fmt.Errorf("invalid default value %q for flag -%s: %v", value, f.Name, verr)
+			case SourceCommandLine:
+				err = fmt.Errorf("invalid value %q for flag -%s: %v", value, f.Name, verr)
+			default:
+				err = fmt.Errorf("%s: invalid value %q for flag -%s: %v", source, value, f.Name, verr)
+			}
+		}
+	})
+	return err
+}
+
+// ExistingFileVar defines a flag naming an existing file with specified
+// name, default value, and usage string. The argument p points to a
+// string variable in which to store the value of the flag. A leading "~"
+// in the value is replaced with the home directory, as for PathVar. After
+// parsing all sources, Parse reports an error if the file doesn't exist
+// or is a directory, so that path errors surface at startup. An empty
+// value is not checked.
+func ExistingFileVar(p *string, name string, value string, usage string) {
+	defaultSet.ExistingFileVar(p, name, value, usage)
+}
+
+// ExistingFileVar defines a flag naming an existing file. See the
+// package-level ExistingFileVar.
+func (fs *FlagSet) ExistingFileVar(p *string, name string, value string, usage string) {
+	fs.Var(newCheckedPathValue[existingFile](value, p), name, usage)
+}
+
+// ExistingFile defines a flag naming an existing file with specified name,
+// default value, and usage string. The return value is the address of a
+// string variable that stores the value of the flag. See ExistingFileVar.
+func ExistingFile(name string, value string, usage string) *string {
+	return defaultSet.ExistingFile(name, value, usage)
+}
+
+// ExistingFile defines a flag naming an existing file. See the
+// package-level ExistingFile.
+func (fs *FlagSet) ExistingFile(name string, value string, usage string) *string {
+	p := new(string)
+	fs.ExistingFileVar(p, name, value, usage)
+	return p
+}
+
+// ExistingDirVar defines a flag naming an existing directory with
+// specified name, default value, and usage string. The argument p points
+// to a string variable in which to store the value of the flag. Parse
+// reports an error if the directory doesn't exist or is not a directory.
+// See ExistingFileVar.
+func ExistingDirVar(p *string, name string, value string, usage string) {
+	defaultSet.ExistingDirVar(p, name, value, usage)
+}
+
+// ExistingDirVar defines a flag naming an existing directory. See the
+// package-level ExistingDirVar.
+func (fs *FlagSet) ExistingDirVar(p *string, name string, value string, usage string) {
+	fs.Var(newCheckedPathValue[existingDir](value, p), name, usage)
+}
+
+// ExistingDir defines a flag naming an existing directory with specified
+// name, default value, and usage string. The return value is the address
+// of a string variable that stores the value of the flag. See
+// ExistingDirVar.
+func ExistingDir(name string, value string, usage string) *string {
+	return defaultSet.ExistingDir(name, value, usage)
+}
+
+// ExistingDir defines a flag naming an existing directory. See the
+// package-level ExistingDir.
+func (fs *FlagSet) ExistingDir(name string, value string, usage string) *string {
+	p := new(string)
+	fs.ExistingDirVar(p, name, value, usage)
+	return p
+}
+
+// CreatableFileVar defines a flag naming a file that the program writes,
+// such as a log or PID file, with specified name, default value, and usage
+// string. The argument p points to a string variable in which to store
+// the value of the flag. Parse reports an error if the file exists but
+// isn't writable or is a directory, or if it doesn't exist and can't be
+// created in its directory. See ExistingFileVar.
+func CreatableFileVar(p *string, name string, value string, usage string) {
+	defaultSet.CreatableFileVar(p, name, value, usage)
+}
+
+// CreatableFileVar defines a flag naming a file that the program writes.
+// See the package-level CreatableFileVar.
+func (fs *FlagSet) CreatableFileVar(p *string, name string, value string, usage string) {
+	fs.Var(newCheckedPathValue[creatableFile](value, p), name, usage)
+}
+
+// CreatableFile defines a flag naming a file that the program writes with
+// specified name, default value, and usage string. The return value is
+// the address of a string variable that stores the value of the flag.
+// See CreatableFileVar.
+func CreatableFile(name string, value string, usage string) *string {
+	return defaultSet.CreatableFile(name, value, usage)
+}
+
+// CreatableFile defines a flag naming a file that the program writes.
+// See the package-level CreatableFile.
+func (fs *FlagSet) CreatableFile(name string, value string, usage string) *string {
+	p := new(string)
+	fs.CreatableFileVar(p, name, value, usage)
+	return p
+}
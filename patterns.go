@@ -0,0 +1,155 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Default patterns of configuration file paths.
+var (
+	defaultGlobalPatterns = []string{"{sysconfdir}/{prog}", "{sysconfdir}/{prog}/config"}
+	defaultUserPatterns   = []string{"~/.{prog}", "{configdir}/{prog}/config"}
+)
+
+// SetConfigPatterns sets patterns of paths where the configuration file of
+// the layer, LayerGlobal or LayerUser, is looked for. The first pattern
+// matching an existing file selects the file of the layer. In patterns,
+// "{prog}" is replaced with the program name, "{sysconfdir}" with the
+// directory of global configuration files, /etc by default, "{configdir}"
+// with the user configuration directory returned by os.UserConfigDir, such
+// as $HOME/.config, and the leading "~" with the home directory. As for
+// any configuration file, a path may also match files with an extension
+// of a known format or ".conf". The default patterns are:
+//
+//	LayerGlobal: {sysconfdir}/{prog}, {sysconfdir}/{prog}/config
+//	LayerUser:   ~/.{prog}, {configdir}/{prog}/config
+//
+// SetConfigPatterns panics for other layers.
+func SetConfigPatterns(layer Layer, patterns ...string) {
+	defaultSet.SetConfigPatterns(layer, patterns...)
+}
+
+// SetConfigPatterns sets patterns of paths where the configuration file of
+// the layer is looked for. See the package-level SetConfigPatterns.
+func (fs *FlagSet) SetConfigPatterns(layer Layer, patterns ...string) {
+	root := fs.root()
+	patterns = append([]string{}, patterns...)
+	switch layer {
+	case LayerGlobal:
+		root.globalPatterns = patterns
+	case LayerUser:
+		root.userPatterns = patterns
+	default:
+		panic("conflag: SetConfigPatterns called for layer " + layer.String())
+	}
+}
+
+// layerPatterns returns patterns of paths for the layer.
+func (fs *FlagSet) layerPatterns(layer Layer) []string {
+	root := fs.root()
+	switch layer {
+	case LayerGlobal:
+		if root.globalPatterns != nil {
+			return root.globalPatterns
+		}
+		return defaultGlobalPatterns
+	case LayerUser:
+		if root.userPatterns != nil {
+			return root.userPatterns
+		}
+		return defaultUserPatterns
+	}
+	return nil
+}
+
+// expandPattern returns the path for the pattern with the given program
+// name, or an error if it can't be expanded.
+func (fs *FlagSet) expandPattern(pattern, progName string) (string, error) {
+	path := strings.ReplaceAll(pattern, "{prog}", progName)
+	path = strings.ReplaceAll(path, "{sysconfdir}", fs.globalConfigDir())
+	if strings.Contains(path, "{configdir}") {
+		dir, err := userConfigDir()
+		if err != nil {
+			return "", err
+		}
+		path = strings.ReplaceAll(path, "{configdir}", dir)
+	}
+	path, err := ExpandHome(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Clean(path), nil
+}
+
+// userConfigDir returns the user configuration directory, which is in
+// the home directory set with SetHomeDir, if any.
+func userConfigDir() (string, error) {
+	if homeDirOverride != "" && os.Getenv("XDG_CONFIG_HOME") == "" {
+		return filepath.Join(homeDirOverride, ".config"), nil
+	}
+	return os.UserConfigDir()
+}
+
+// findLayerFile returns the path of the configuration file of the layer:
+// the first existing file matching its patterns, or the path for the first
+// pattern if there's none. If the file is found only for an alias of the
+// program name, it also returns the alias. It returns an empty string if
+// program name is not set, and a *homeError if no pattern of the user
+// configuration file can be expanded.
+func (fs *FlagSet) findLayerFile(layer Layer) (path, alias string, err error) {
+	progName := fs.ProgName()
+	if progName == "" {
+		return "", "", nil
+	}
+	first := ""
+	var expandErr error
+	for _, name := range append([]string{progName}, fs.root().progNameAliases...) {
+		for _, pattern := range fs.layerPatterns(layer) {
+			base, err := fs.expandPattern(pattern, name)
+			if err != nil {
+				expandErr = err
+				continue
+			}
+			path, found, err := fs.findConfigFile(base)
+			if found || err != nil {
+				if name == progName {
+					name = ""
+				}
+				return path, name, err
+			}
+			if first == "" {
+				first = path
+			}
+		}
+	}
+	if first == "" && expandErr != nil && layer == LayerUser {
+		return "", "", &homeError{expandErr}
+	}
+	return first, "", nil
+}
+
+// SetProgNameAliases sets program name, as SetProgName does, and its
+// aliases, such as names of the program before it was renamed. If no
+// configuration file is found for the program name, files for the aliases
+// are looked for, and a deprecation notice is printed when such a file
+// is loaded.
+func SetProgNameAliases(name string, aliases ...string) {
+	defaultSet.SetProgNameAliases(name, aliases...)
+}
+
+// SetProgNameAliases sets program name and its aliases, which are used
+// for locating legacy configuration files.
+func (fs *FlagSet) SetProgNameAliases(name string, aliases ...string) {
+	for _, alias := range aliases {
+		if alias == "" || strings.ContainsRune(alias, filepath.Separator) {
+			panic("conflag: SetProgNameAliases called with bad alias")
+		}
+	}
+	fs.SetProgName(name)
+	fs.root().progNameAliases = append([]string{}, aliases...)
+}
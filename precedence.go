@@ -0,0 +1,108 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// A Layer is a source of flag values.
+type Layer int
+
+// Layers of flag values, which are applied on top of the default values
+// in the order set by SetPrecedence.
+const (
+	LayerGlobal      Layer = iota // global configuration file, /etc/progname
+	LayerUser                     // user configuration file, $HOME/.progname, or files set by SetSearchPaths
+	LayerDotEnv                   // .env file set by SetDotEnvFile
+	LayerEnv                      // environment variables, such as PROGNAME_MAX_CONNS
+	LayerCommandLine              // command-line arguments
+	LayerRegistry                 // Windows registry, HKLM\Software\progname and HKCU\Software\progname
+	LayerDefault                  // default configuration set by SetDefaultConfig
+)
+
+var layerNames = [...]string{"global", "user", "dotenv", "env", "command line", "registry", "default"}
+
+func (l Layer) String() string {
+	if l < 0 || int(l) >= len(layerNames) {
+		return fmt.Sprintf("Layer(%d)", int(l))
+	}
+	return layerNames[l]
+}
+
+// defaultPrecedence is the default order of layers.
+var defaultPrecedence = []Layer{LayerDefault, LayerGlobal, LayerUser, LayerDotEnv, LayerCommandLine}
+
+// SetPrecedence sets the order in which layers of flag values are applied
+// on top of the defaults, from the lowest precedence to the highest: values
+// from later layers override values from earlier ones. The default order
+// is LayerDefault, LayerGlobal, LayerUser, LayerDotEnv, LayerCommandLine.
+// Layers that are not listed are not loaded, so LayerEnv must be listed to
+// set flags from environment variables, which are named after the program
+// and the flag in upper case, with characters other than letters and
+// digits replaced with underscores, such as MYCMD_MAX_CONNS for the flag
+// "max-conns" of program "mycmd":
+//
+//	flag.SetPrecedence(flag.LayerDefault, flag.LayerGlobal, flag.LayerUser, flag.LayerEnv, flag.LayerCommandLine)
+//
+// Placing LayerCommandLine before configuration files makes them override
+// the command line, which is useful for centrally managed machines.
+//
+// On Windows, listing LayerRegistry sets flags from values of the
+// HKEY_LOCAL_MACHINE\Software\progname key and then of the
+// HKEY_CURRENT_USER\Software\progname key, named after the flags, such
+// as "max-conns", so that settings distributed by group policy apply.
+// String values are used as is, integer values in decimal, and each
+// string of a multi-string value is a separate value for the flag, as if
+// the flag was repeated. On other systems, the layer has no values.
+//
+// SetPrecedence panics if LayerCommandLine is not listed or if a layer is
+// listed more than once.
+func SetPrecedence(layers ...Layer) {
+	defaultSet.SetPrecedence(layers...)
+}
+
+// SetPrecedence sets the order in which layers of flag values are applied.
+// See the package-level SetPrecedence.
+func (fs *FlagSet) SetPrecedence(layers ...Layer) {
+	seen := make(map[Layer]bool)
+	for _, l := range layers {
+		if l < LayerGlobal || l > LayerDefault || seen[l] {
+			panic(fmt.Sprintf("conflag: bad or repeated layer %d in SetPrecedence", l))
+		}
+		seen[l] = true
+	}
+	if !seen[LayerCommandLine] {
+		panic("conflag: SetPrecedence called without LayerCommandLine")
+	}
+	fs.root().precedence = append([]Layer(nil), layers...)
+}
+
+// layers returns layers in the order of precedence.
+func (fs *FlagSet) layers() []Layer {
+	if fs.precedence != nil {
+		return fs.precedence
+	}
+	return defaultPrecedence
+}
+
+// envEntries returns entries for flags set in environment variables.
+func (fs *FlagSet) envEntries() (entries []entry) {
+	fs.VisitAll(func(f *flag.Flag) {
+		name := fs.flagEnvName(f.Name)
+		if v, ok := os.LookupEnv(name); ok {
+			entries = append(entries, entry{
+				name:     f.Name,
+				value:    v,
+				hasValue: true,
+				literal:  true,
+				file:     "$" + name,
+			})
+		}
+	})
+	return
+}
@@ -0,0 +1,178 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Probe checks that the resource described by the value of a flag is
+// usable, for example, that a listen address can be bound or that a
+// directory exists and has enough free space. It returns nil if the resource is ready.
+type Probe func(f *flag.Flag) error
+
+// ProbeResult is the outcome of running a single probe.
+type ProbeResult struct {
+	Flag  string // flag name
+	Value string // flag value at the time of the check, masked if sensitive
+	Err   error  // nil if the probe succeeded
+}
+
+type flagProbe struct {
+	name  string
+	probe Probe
+}
+
+// AddProbe registers a probe for the named flag, which must be defined,
+// to be run by RunProbes and Doctor. Multiple probes may be registered
+// for one flag; they are run in the order of registration.
+func AddProbe(name string, probe Probe) {
+	defaultSet.AddProbe(name, probe)
+}
+
+// AddProbe registers a probe for the named flag.
+func (fs *FlagSet) AddProbe(name string, probe Probe) {
+	name = fs.canonicalName(name)
+	if fs.FlagSet.Lookup(name) == nil {
+		panic(fmt.Sprintf("conflag: adding probe for undefined flag %s", name))
+	}
+	fs.probes = append(fs.probes, flagProbe{name, probe})
+}
+
+// RunProbes runs all registered probes against the current flag values
+// and returns their results. It should be called after Parse.
+func RunProbes() []ProbeResult {
+	return defaultSet.RunProbes()
+}
+
+// RunProbes runs all registered probes against the current flag values
+// and returns their results.
+func (fs *FlagSet) RunProbes() []ProbeResult {
+	results := make([]ProbeResult, 0, len(fs.probes))
+	for _, p := range fs.probes {
+		f := fs.FlagSet.Lookup(p.name)
+		r := ProbeResult{Flag: p.name, Value: fs.displayValue(f), Err: p.probe(f)}
+		results = append(results, r)
+	}
+	return results
+}
+
+// Doctor runs all registered probes, writes a report to w, and returns
+// true if every probe succeeded. It is intended for implementing a
+// "doctor" or "check" subcommand:
+//
+//	if flag.Arg(0) == "doctor" {
+//		if !flag.Doctor(os.Stdout) {
+//			os.Exit(1)
+//		}
+//		return
+//	}
+func Doctor(w io.Writer) bool {
+	return defaultSet.Doctor(w)
+}
+
+// Doctor runs all registered probes, writes a report to w, and returns
+// true if every probe succeeded.
+func (fs *FlagSet) Doctor(w io.Writer) bool {
+	ok := true
+	for _, r := range fs.RunProbes() {
+		if r.Err != nil {
+			ok = false
+			fmt.Fprintf(w, "FAIL  -%s=%s: %s\n", r.Flag, r.Value, r.Err)
+		} else {
+			fmt.Fprintf(w, "ok    -%s=%s\n", r.Flag, r.Value)
+		}
+	}
+	if ok {
+		fmt.Fprintln(w, "ready")
+	} else {
+		fmt.Fprintln(w, "not ready")
+	}
+	return ok
+}
+
+// ProbeListen is a probe that checks whether the flag value is a listen
+// address, in the form accepted by ParseListenAddr, that can be listened on.
+func ProbeListen(f *flag.Flag) error {
+	a, err := ParseListenAddr(f.Value.String())
+	if err != nil {
+		return err
+	}
+	l, err := a.Listen()
+	if err != nil {
+		return err
+	}
+	return l.Close()
+}
+
+// ProbeDir is a probe that checks whether the flag value names an existing
+// directory in which files can be created.
+func ProbeDir(f *flag.Flag) error {
+	dir := f.Value.String()
+	if dir == "" {
+		return errors.New("directory is not set")
+	}
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	tmp, err := os.CreateTemp(dir, ".probe")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	return os.Remove(tmp.Name())
+}
+
+// ProbeFreeSpace returns a probe that checks whether the flag value names
+// an existing directory in which files can be created, as ProbeDir, on a
+// file system with at least need bytes available. On systems where free
+// space can't be determined, only the directory is checked.
+func ProbeFreeSpace(need uint64) Probe {
+	return func(f *flag.Flag) error {
+		if err := ProbeDir(f); err != nil {
+			return err
+		}
+		free, err := freeSpace(f.Value.String())
+		if errors.Is(err, errors.ErrUnsupported) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if free < need {
+			return fmt.Errorf("only %d bytes available, need %d", free, need)
+		}
+		return nil
+	}
+}
+
+// ProbeDial returns a probe that checks whether a connection can be
+// established to the address in the flag value within the given timeout.
+// The value may be either host:port or a URL with the host:port part,
+// such as "postgres://user@db:5432/app".
+func ProbeDial(network string, timeout time.Duration) Probe {
+	return func(f *flag.Flag) error {
+		addr := f.Value.String()
+		if u, err := url.Parse(addr); err == nil && u.Host != "" {
+			addr = u.Host
+		}
+		c, err := net.DialTimeout(network, addr, timeout)
+		if err != nil {
+			return err
+		}
+		return c.Close()
+	}
+}
@@ -0,0 +1,14 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !(linux || darwin || freebsd)
+
+package conflag
+
+import "errors"
+
+// freeSpace is not implemented on this system.
+func freeSpace(dir string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
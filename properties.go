@@ -0,0 +1,109 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// decodeProperties returns entries of the Java properties file. Keys are
+// separated from values by "=", ":", or whitespace, lines ending with an
+// odd number of backslashes continue on the next line, and keys and values
+// may contain escape sequences, such as "\t" and "\u00e9". Lines starting
+// with "#" or "!" are comments. The file is read as UTF-8.
+func decodeProperties(filename string, data []byte) ([]entry, error) {
+	lines := strings.Split(strings.TrimPrefix(string(data), utf8BOM), "\n")
+	var entries []entry
+	for n := 0; n < len(lines); n++ {
+		e := entry{file: filename, line: n + 1}
+		line := strings.TrimLeft(strings.TrimSuffix(lines[n], "\r"), " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		// Join continuation lines, dropping their leading whitespace.
+		for continues(line) && n+1 < len(lines) {
+			n++
+			line = line[:len(line)-1] + strings.TrimLeft(strings.TrimSuffix(lines[n], "\r"), " \t\f")
+		}
+		if continues(line) {
+			line = line[:len(line)-1]
+		}
+		// Find the end of the key at the first unescaped separator.
+		end := len(line)
+		for i := 0; i < len(line); i++ {
+			if line[i] == '\\' {
+				i++
+				continue
+			}
+			if strings.IndexByte("=: \t\f", line[i]) >= 0 {
+				end = i
+				break
+			}
+		}
+		key, rest := line[:end], strings.TrimLeft(line[end:], " \t\f")
+		if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+			rest = strings.TrimLeft(rest[1:], " \t\f")
+			e.hasValue = true
+		} else if end < len(line) {
+			e.hasValue = rest != ""
+		}
+		var err error
+		if e.name, err = unescapeProperty(key); err != nil {
+			return nil, e.errorf("%s", err)
+		}
+		if e.value, err = unescapeProperty(rest); err != nil {
+			return nil, e.errorf("%s", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// continues reports whether the line ends with an odd number of
+// backslashes, so that it continues on the next line.
+func continues(line string) bool {
+	n := len(line) - len(strings.TrimRight(line, `\`))
+	return n%2 == 1
+}
+
+// unescapeProperty interprets escape sequences in the key or value.
+func unescapeProperty(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", fmt.Errorf("bad escape sequence \\%s", s[i:])
+			}
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("bad escape sequence \\%s", s[i:i+5])
+			}
+			b.WriteRune(rune(r))
+			i += 4
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"log/slog"
+	"os"
+)
+
+// Names of configuration sources other than files in messages.
+const (
+	stdinName         = "<stdin>"
+	defaultConfigName = "<default config>"
+)
+
+// SetDefaultConfig sets the default configuration, which is loaded before
+// all configuration files, so that its values override only the default
+// values of flags. It's typically compiled into the program with the
+// go:embed directive, to ship defaults without installing a file:
+//
+//	//go:embed defaults.conf
+//	var defaults []byte
+//
+//	flag.SetDefaultConfig(defaults)
+//
+// The format is the one set by SetConfigFormat, or the native format,
+// with JSON detected automatically. The configuration is loaded as
+// LayerDefault, which must be listed if the order of layers is changed
+// with SetPrecedence.
+func SetDefaultConfig(data []byte) {
+	defaultSet.SetDefaultConfig(data)
+}
+
+// SetDefaultConfig sets the default configuration, which is loaded
+// before all configuration files. See the package-level SetDefaultConfig.
+func (fs *FlagSet) SetDefaultConfig(data []byte) {
+	fs.root().defaultConfig = data
+}
+
+// ParseReader sets flags from the configuration read from r, such as
+// os.Stdin, in the same way as from a configuration file. The format is
+// the one set by SetConfigFormat, or the native format, with JSON
+// detected automatically. It doesn't load configuration files or parse
+// the command line, so it's usually called before Parse, which overrides
+// the values with those from configuration files and the command line.
+// References to other flags in values are resolved, and constraints and
+// values of flags are checked, by Parse after all sources are applied.
+func ParseReader(r io.Reader) error {
+	return defaultSet.ParseReader(r)
+}
+
+// ParseReader sets flags from the configuration read from r.
+// See the package-level ParseReader.
+func (fs *FlagSet) ParseReader(r io.Reader) error {
+	name := "<input>"
+	if r == os.Stdin {
+		name = stdinName
+	}
+	data, err := io.ReadAll(r)
+	if err == nil {
+		err = fs.applyConfig(name, data)
+	}
+	if err != nil {
+		return fs.failConfig(err)
+	}
+	return nil
+}
+
+// ParseFS sets flags from the configuration files at the given paths in
+// fsys, such as an embed.FS, in the same way as from configuration files
+// on disk: they are applied in the given order, so that values from later
+// files override those from earlier ones, and missing files are skipped.
+// The format is detected from the file name extension. Like ParseReader,
+// it doesn't parse the command line, and references to other flags are
+// resolved by the following Parse.
+func ParseFS(fsys iofs.FS, paths ...string) error {
+	return defaultSet.ParseFS(fsys, paths...)
+}
+
+// ParseFS sets flags from the configuration files in fsys.
+// See the package-level ParseFS.
+func (fs *FlagSet) ParseFS(fsys iofs.FS, paths ...string) error {
+	for _, path := range paths {
+		data, err := iofs.ReadFile(fsys, path)
+		if errors.Is(err, iofs.ErrNotExist) {
+			fs.logf(LevelDebug, "config file %s not found", path)
+			continue
+		}
+		if err != nil {
+			err = fs.unreadable(fmt.Errorf("error opening config file %q: %s", path, err))
+		} else {
+			fs.tracef("read %s", path)
+			fs.event(slog.LevelDebug, "config file read", slog.String("file", path))
+			err = fs.applyConfig(path, data)
+		}
+		if err != nil {
+			return fs.failConfig(err)
+		}
+	}
+	return nil
+}
+
+// applyConfig sets flags from the configuration data of the named file.
+func (fs *FlagSet) applyConfig(filename string, data []byte) error {
+	entries, err := fs.decodeConfig(filename, data)
+	if err == nil {
+		entries, err = fs.checkDuplicates(entries)
+	}
+	if err == nil {
+		entries, err = fs.selectSections(entries)
+	}
+	if err != nil {
+		return err
+	}
+	return fs.applyEntries(entries)
+}
+
+// readStdin returns the configuration read from the standard input. It's
+// read only once, so that commands share it with the top-level set.
+func (fs *FlagSet) readStdin() ([]byte, error) {
+	root := fs.root()
+	if root.stdin == nil {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		root.stdin = append([]byte{}, data...)
+	}
+	return root.stdin, nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var flagRefRegexp = regexp.MustCompile(`\$\{flag:([^}]*)\}`)
+
+// hasFlagRefs reports whether the value refers to other flags.
+func hasFlagRefs(value string) bool {
+	return strings.Contains(value, "${flag:")
+}
+
+// resolveRefs substitutes references to other flags in pending entries,
+// which are applied after all sources are parsed unless the flag is set
+// again from another source, and sets the corresponding flags. Pending
+// entries are cleared.
+func (fs *FlagSet) resolveRefs() error {
+	defer func() { fs.pendingRefs = make(map[string]entry) }()
+	names := make([]string, 0, len(fs.pendingRefs))
+	for name := range fs.pendingRefs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var path []string
+	var resolve func(name string) error
+	resolve = func(name string) error {
+		e, ok := fs.pendingRefs[name]
+		if !ok {
+			return nil
+		}
+		for i, p := range path {
+			if p == name {
+				return e.errorf("reference cycle: -%s", strings.Join(append(path[i:], name), " -> -"))
+			}
+		}
+		path = append(path, name)
+		defer func() { path = path[:len(path)-1] }()
+		var err error
+		value := flagRefRegexp.ReplaceAllStringFunc(e.value, func(ref string) string {
+			ref = flagRefRegexp.FindStringSubmatch(ref)[1]
+			f := fs.Lookup(fs.normalizeKey(ref))
+			if f == nil {
+				if err == nil {
+					err = e.errorf("reference to undefined flag -%s", ref)
+				}
+				return ""
+			}
+			if rerr := resolve(f.Name); rerr != nil && err == nil {
+				err = rerr
+			}
+			return f.Value.String()
+		})
+		if err != nil {
+			return err
+		}
+		f := fs.Lookup(name)
+		if err := fs.setFlag(f, e.name, value, e.file); err != nil {
+			return e.invalidValue(fs, f, value, err)
+		}
+		return nil
+	}
+	for _, name := range names {
+		if err := resolve(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
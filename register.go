@@ -0,0 +1,185 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"encoding"
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Register defines a flag for each exported field of the struct pointed to
+// by v, binding the flag to the field. The field's tag describes the flag:
+//
+//	type Config struct {
+//		Listen  string        `conflag:"listen,listen address,:8080"`
+//		Timeout time.Duration `conflag:",request timeout"`
+//		Debug   bool          `conflag:"-"`
+//		DB      struct {
+//			MaxConns int `conflag:"max-conns,maximum number of connections,10"`
+//		} `conflag:"db"`
+//	}
+//
+// The tag consists of the flag name, usage string and default value
+// separated by commas; the default value is the rest of the tag and may
+// contain commas. If the name is empty, it's the field name converted by
+// NormalizeName, and if the default value is empty, it's the current
+// value of the field. Fields tagged "-" are skipped.
+//
+// Fields of nested structs define flags whose names are prefixed with
+// the struct field's name and a dot, such as "db.max-conns", which can
+// be set in the "[db]" section of configuration files. Fields of embedded
+// structs without tags are defined without a prefix.
+//
+// Fields may be of types bool, int, int64, uint, uint64, float64, string,
+// time.Duration, or implement flag.Value or encoding.TextUnmarshaler.
+// Register panics if v isn't a pointer to a struct or a field has an
+// unsupported type.
+func Register(v any) {
+	defaultSet.Register(v)
+}
+
+// Register defines flags bound to fields of the struct pointed to by v.
+// See the package-level Register.
+func (fs *FlagSet) Register(v any) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("conflag: Register called with %T, not a pointer to struct", v))
+	}
+	walkStruct(rv.Elem(), "", fs.registerField)
+}
+
+// Unmarshal stores the values of flags in the fields of the struct pointed
+// to by v. It should be called after Parse. Fields are matched to flags
+// by names derived in the same way as by Register; fields for which no
+// flag is defined are left unchanged.
+func Unmarshal(v any) error {
+	return defaultSet.Unmarshal(v)
+}
+
+// Unmarshal stores the values of flags in the fields of the struct pointed
+// to by v. See the package-level Unmarshal.
+func (fs *FlagSet) Unmarshal(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("conflag: Unmarshal called with %T, not a pointer to struct", v)
+	}
+	var err error
+	walkStruct(rv.Elem(), "", func(fv reflect.Value, sf reflect.StructField, name, _, _ string) {
+		f := fs.Lookup(name)
+		if f == nil || err != nil {
+			return
+		}
+		if g, ok := f.Value.(flag.Getter); ok {
+			if gv := reflect.ValueOf(g.Get()); gv.IsValid() && gv.Type().AssignableTo(fv.Type()) {
+				fv.Set(gv)
+				return
+			}
+		}
+		tmp := flag.NewFlagSet(name, flag.ContinueOnError)
+		if !defineField(tmp, fv, name, "") {
+			err = fmt.Errorf("conflag: field %s has unsupported type %s", sf.Name, sf.Type)
+			return
+		}
+		if e := tmp.Set(name, f.Value.String()); e != nil {
+			err = fmt.Errorf("conflag: field %s: %v", sf.Name, e)
+		}
+	})
+	return err
+}
+
+var (
+	valueType     = reflect.TypeOf((*flag.Value)(nil)).Elem()
+	unmarshalType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// walkStruct calls leaf for each exported field of the struct value sv
+// that isn't a nested struct, with the flag name prefixed by prefix, and
+// the usage and default value from the field's tag.
+func walkStruct(sv reflect.Value, prefix string,
+	leaf func(fv reflect.Value, sf reflect.StructField, name, usage, def string)) {
+	st := sv.Type()
+	for i := 0; i < st.NumField(); i++ {
+		sf := st.Field(i)
+		tag, hasTag := sf.Tag.Lookup("conflag")
+		if tag == "-" || !sf.IsExported() {
+			continue
+		}
+		name, rest, _ := strings.Cut(tag, ",")
+		usage, def, _ := strings.Cut(rest, ",")
+		if name == "" {
+			name = NormalizeName(sf.Name)
+		}
+		fv := sv.Field(i)
+		if fv.Kind() == reflect.Pointer && fv.Type().Elem().Kind() == reflect.Struct &&
+			!fv.Type().Implements(valueType) && !fv.Type().Implements(unmarshalType) {
+			if fv.IsNil() {
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct &&
+			!fv.Addr().Type().Implements(valueType) && !fv.Addr().Type().Implements(unmarshalType) {
+			if sf.Anonymous && !hasTag {
+				walkStruct(fv, prefix, leaf)
+			} else {
+				walkStruct(fv, prefix+name+".", leaf)
+			}
+			continue
+		}
+		leaf(fv, sf, prefix+name, usage, def)
+	}
+}
+
+// registerField defines the named flag bound to the field value fv.
+func (fs *FlagSet) registerField(fv reflect.Value, sf reflect.StructField, name, usage, def string) {
+	if !defineField(fs.FlagSet, fv, name, usage) {
+		panic(fmt.Sprintf("conflag: field %s has unsupported type %s", sf.Name, sf.Type))
+	}
+	if def != "" {
+		f := fs.FlagSet.Lookup(name)
+		if err := f.Value.Set(def); err != nil {
+			panic(fmt.Sprintf("conflag: invalid default value %q for field %s: %v", def, sf.Name, err))
+		}
+		f.DefValue = f.Value.String()
+	}
+}
+
+// defineField defines the named flag in set bound to the field value fv,
+// and reports whether the field's type is supported.
+func defineField(set *flag.FlagSet, fv reflect.Value, name, usage string) bool {
+	switch p := fv.Addr().Interface().(type) {
+	case flag.Value:
+		set.Var(p, name, usage)
+	case encoding.TextUnmarshaler:
+		m, ok := p.(encoding.TextMarshaler)
+		if !ok {
+			return false
+		}
+		set.TextVar(p, name, m, usage)
+	case *bool:
+		set.BoolVar(p, name, *p, usage)
+	case *int:
+		set.IntVar(p, name, *p, usage)
+	case *int64:
+		set.Int64Var(p, name, *p, usage)
+	case *uint:
+		set.UintVar(p, name, *p, usage)
+	case *uint64:
+		set.Uint64Var(p, name, *p, usage)
+	case *float64:
+		set.Float64Var(p, name, *p, usage)
+	case *string:
+		set.StringVar(p, name, *p, usage)
+	case *time.Duration:
+		set.DurationVar(p, name, *p, usage)
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,13 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !windows
+
+package conflag
+
+// registryEntries returns no entries, since there's no registry outside
+// of Windows.
+func (fs *FlagSet) registryEntries() ([]entry, error) {
+	return nil, nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+	"unicode/utf16"
+)
+
+// registryEntries returns entries for flags set in values of the
+// HKEY_LOCAL_MACHINE\Software\progname key, followed by those of the
+// HKEY_CURRENT_USER\Software\progname key.
+func (fs *FlagSet) registryEntries() ([]entry, error) {
+	var entries []entry
+	for _, k := range []struct {
+		root syscall.Handle
+		name string
+	}{
+		{syscall.HKEY_LOCAL_MACHINE, `HKLM`},
+		{syscall.HKEY_CURRENT_USER, `HKCU`},
+	} {
+		path := `Software\` + fs.ProgName()
+		e, err := fs.readRegistryKey(k.root, path, k.name+`\`+path)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e...)
+	}
+	return entries, nil
+}
+
+// readRegistryKey returns entries for flags set in values of the key.
+func (fs *FlagSet) readRegistryKey(root syscall.Handle, path, location string) (entries []entry, err error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, nil
+	}
+	var key syscall.Handle
+	if err := syscall.RegOpenKeyEx(root, p, 0, syscall.KEY_READ, &key); err != nil {
+		if err == syscall.ERROR_FILE_NOT_FOUND {
+			fs.logf(LevelDebug, "registry key %s not found", location)
+			return nil, nil
+		}
+		return nil, fs.unreadable(fmt.Errorf("error opening registry key %s: %s", location, err))
+	}
+	defer syscall.RegCloseKey(key)
+	fs.tracef("read %s", location)
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+		var values []string
+		if values, err = queryRegistryValue(key, f.Name); err != nil {
+			err = fmt.Errorf("%s: value %s: %s", location, f.Name, err)
+			return
+		}
+		for _, v := range values {
+			entries = append(entries, entry{
+				name:     f.Name,
+				value:    v,
+				hasValue: true,
+				literal:  true,
+				file:     location,
+			})
+		}
+	})
+	return entries, err
+}
+
+// queryRegistryValue returns the named value of the key as strings:
+// none if the value doesn't exist, and one for each string of REG_MULTI_SZ
+// values. Integer values are returned in decimal.
+func queryRegistryValue(key syscall.Handle, name string) ([]string, error) {
+	p, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, nil
+	}
+	var typ, n uint32
+	if err := syscall.RegQueryValueEx(key, p, nil, &typ, nil, &n); err != nil {
+		if err == syscall.ERROR_FILE_NOT_FOUND {
+			return nil, nil
+		}
+		return nil, err
+	}
+	buf := make([]byte, n)
+	if n > 0 {
+		if err := syscall.RegQueryValueEx(key, p, nil, &typ, &buf[0], &n); err != nil {
+			return nil, err
+		}
+		buf = buf[:n]
+	}
+	switch typ {
+	case syscall.REG_SZ, syscall.REG_EXPAND_SZ:
+		return []string{decodeUTF16(buf)}, nil
+	case syscall.REG_MULTI_SZ:
+		s := strings.TrimRight(string(utf16.Decode(utf16Units(buf))), "\x00")
+		if s == "" {
+			return nil, nil
+		}
+		return strings.Split(s, "\x00"), nil
+	case syscall.REG_DWORD:
+		if len(buf) != 4 {
+			return nil, fmt.Errorf("bad REG_DWORD length %d", len(buf))
+		}
+		return []string{strconv.FormatUint(uint64(binary.LittleEndian.Uint32(buf)), 10)}, nil
+	case syscall.REG_QWORD:
+		if len(buf) != 8 {
+			return nil, fmt.Errorf("bad REG_QWORD length %d", len(buf))
+		}
+		return []string{strconv.FormatUint(binary.LittleEndian.Uint64(buf), 10)}, nil
+	}
+	return nil, fmt.Errorf("unsupported value type %d", typ)
+}
+
+// utf16Units returns the UTF-16 code units of the little-endian data.
+func utf16Units(b []byte) []uint16 {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return u
+}
+
+// decodeUTF16 returns the string of the little-endian UTF-16 data up to
+// the terminating NUL.
+func decodeUTF16(b []byte) string {
+	u := utf16Units(b)
+	for i, c := range u {
+		if c == 0 {
+			u = u[:i]
+			break
+		}
+	}
+	return string(utf16.Decode(u))
+}
@@ -0,0 +1,117 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// reloadStats counts calls to Reload, which are published by PublishExpvar.
+type reloadStats struct {
+	succeeded, failed int
+	last              time.Time // time of the last reload
+}
+
+// MarkReloadable marks the named flags as reloadable, so that Reload
+// applies their new values. Changes to other flags, such as a listen
+// address, which the program uses only at startup, are reported by
+// Reload as warnings advising a restart instead of being half-applied.
+func MarkReloadable(names ...string) {
+	defaultSet.MarkReloadable(names...)
+}
+
+// MarkReloadable marks the named flags as reloadable.
+// See the package-level MarkReloadable.
+func (fs *FlagSet) MarkReloadable(names ...string) {
+	for _, name := range names {
+		if fs.FlagSet.Lookup(name) == nil {
+			panic(fmt.Sprintf("conflag: marking undefined flag %s as reloadable", name))
+		}
+		if fs.reloadable == nil {
+			fs.reloadable = make(map[string]bool)
+		}
+		fs.reloadable[name] = true
+	}
+}
+
+// Reload parses configuration files and other sources again with the
+// arguments passed to the last Parse, for example, when the program
+// receives SIGHUP, and applies the changed values of flags marked with
+// MarkReloadable, calling functions registered with OnChange. Changes to
+// other flags are not applied, but logged as warnings. If parsing fails,
+// no flags are changed and the error is returned, regardless of the error
+// handling property of the flag set. Programs should read reloadable
+// flags with getters, such as GetString, which are synchronized with
+// changes.
+func Reload() error {
+	return defaultSet.Reload()
+}
+
+// Reload parses configuration again and applies changed values of
+// reloadable flags. It must be called on the top-level flag set.
+// See the package-level Reload.
+func (fs *FlagSet) Reload() error {
+	s := fs.shadow(nil)
+	err := s.Parse(fs.arguments)
+	if fs.stdin == nil {
+		// Standard input can be read only once.
+		fs.stdin = s.stdin
+	}
+	runtimeMu.Lock()
+	fs.reloads.last = time.Now()
+	if err != nil {
+		fs.reloads.failed++
+	} else {
+		fs.reloads.succeeded++
+	}
+	runtimeMu.Unlock()
+	if err != nil {
+		return err
+	}
+	fs.reload(s)
+	return nil
+}
+
+// reload applies changes of reloadable flags from the parsed shadow set.
+func (fs *FlagSet) reload(s *FlagSet) {
+	type change struct{ name, old, new string }
+	var changes []change
+	runtimeMu.Lock()
+	fs.VisitAll(func(f *flag.Flag) {
+		nf := s.FlagSet.Lookup(f.Name)
+		old, v := f.Value.String(), nf.Value.String()
+		if v == old {
+			return
+		}
+		source := s.Source(f.Name)
+		if !fs.reloadable[f.Name] {
+			fs.logf(LevelWarn, "%s: flag -%s changed to %s, restart the program to apply", source, f.Name, fs.traceValue(f.Name, v))
+			return
+		}
+		if dst, src := reflect.ValueOf(f.Value), reflect.ValueOf(nf.Value); dst.Type() == src.Type() && dst.Kind() == reflect.Pointer {
+			// Copy the variable, since setting values holding lists
+			// from their string form may append to them.
+			dst.Elem().Set(src.Elem())
+		} else if err := f.Value.Set(v); err != nil {
+			fs.logf(LevelWarn, "%s: can't reload flag -%s: %v", source, f.Name, err)
+			return
+		}
+		fs.setEvent(f, source, fs.Source(f.Name))
+		fs.sources[f.Name] = source
+		changes = append(changes, change{f.Name, old, f.Value.String()})
+	})
+	runtimeMu.Unlock()
+	for _, c := range changes {
+		for _, fn := range fs.onChange {
+			fn(c.name, c.old, c.new)
+		}
+	}
+	if fs.selected != nil && s.selected != nil && fs.selected.command == s.selected.command {
+		fs.selected.reload(s.selected)
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"flag"
+	"os"
+)
+
+// ResetForTesting replaces the default set with a new one, clearing all
+// flags, commands and settings, such as the program name, search paths
+// and policies, and resets the home directory set with SetHomeDir and the
+// arguments set with SetArgs, so that tests can define flags and call
+// Parse with different configuration files and arguments in the same
+// process. The new set continues parsing after errors, which are printed,
+// instead of exiting the program. The usage function, if not nil, is
+// called on errors.
+func ResetForTesting(usage func()) {
+	defaultSet = New(os.Args[0], flag.ContinueOnError)
+	if usage != nil {
+		defaultSet.Usage = usage
+	}
+	homeDirOverride = ""
+	defaultArgs = nil
+}
+
+// resetParse clears the state left by the previous call to Parse, so that
+// the set can be parsed again. Values of flags and their sources are kept,
+// as are references to other flags read by ParseReader and ParseFS.
+func (fs *FlagSet) resetParse() {
+	fs.selected = nil
+	fs.configArgs, fs.configArgsFrom = nil, ""
+	fs.warnedDeprecated = make(map[string]bool)
+	if fs.parent == nil {
+		fs.filesUsed, fs.unusedKeys = nil, nil
+		fs.userConfigSkipped = false
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"flag"
+	"fmt"
+)
+
+// MarkConfigOnly makes the named flags settable only from configuration
+// files and other sources, such as environment variables, but not from
+// the command line, where Parse rejects them. Use it for secrets, such as
+// passwords, which would otherwise leak into shell history and process
+// listings.
+func MarkConfigOnly(names ...string) {
+	defaultSet.MarkConfigOnly(names...)
+}
+
+// MarkConfigOnly makes the named flags settable only from sources other
+// than the command line. See the package-level MarkConfigOnly.
+func (fs *FlagSet) MarkConfigOnly(names ...string) {
+	for _, name := range names {
+		if fs.FlagSet.Lookup(name) == nil {
+			panic(fmt.Sprintf("conflag: marking undefined flag %s as config-only", name))
+		}
+		if fs.configOnly == nil {
+			fs.configOnly = make(map[string]bool)
+		}
+		fs.configOnly[name] = true
+	}
+}
+
+// MarkCLIOnly makes the named flags settable only on the command line.
+// Parse rejects them in configuration files and other sources, such as
+// environment variables, so that per-invocation flags, such as -force,
+// can't become persistent defaults. WriteExampleConfig and Save omit them.
+func MarkCLIOnly(names ...string) {
+	defaultSet.MarkCLIOnly(names...)
+}
+
+// MarkCLIOnly makes the named flags settable only on the command line.
+// See the package-level MarkCLIOnly.
+func (fs *FlagSet) MarkCLIOnly(names ...string) {
+	for _, name := range names {
+		if fs.FlagSet.Lookup(name) == nil {
+			panic(fmt.Sprintf("conflag: marking undefined flag %s as CLI-only", name))
+		}
+		if fs.cliOnly == nil {
+			fs.cliOnly = make(map[string]bool)
+		}
+		fs.cliOnly[name] = true
+	}
+}
+
+// checkArgs returns an error if the arguments set flags that can't be
+// set on the command line. It doesn't include values in the error, since
+// they may be secret.
+func (fs *FlagSet) checkArgs(arguments []string) (err error) {
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || !fs.configOnly[f.Name] {
+			return
+		}
+		if _, ok := fs.lookupArg(arguments, f.Name); ok {
+			err = fmt.Errorf("flag -%s can't be set on the command line, only in configuration files", f.Name)
+		}
+	})
+	return
+}
@@ -0,0 +1,140 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A SchemaDescriber is a flag value that describes constraints on its
+// values, such as the allowed choices or a range, by returning JSON
+// Schema keywords, such as "enum", "minimum", or "maximum". They are
+// added to the description of the flag written by WriteJSONSchema.
+type SchemaDescriber interface {
+	JSONSchema() map[string]interface{}
+}
+
+// WriteJSONSchema writes to w a JSON Schema describing configuration
+// files: an object with a property for each visible flag, holding the
+// type of its value, the default value, and the usage string as the
+// description. Keys of flags with dotted names, such as "db.max-conns",
+// are described as nested objects, as in TOML tables; if the key of
+// another flag is the name of such a table, for example, if flags "db"
+// and "db.max-conns" are both defined, the schema can't describe them and
+// WriteJSONSchema returns an error. List flags may be given as arrays or
+// as strings with delimited elements. Sensitive flags
+// are marked as write-only and their defaults are omitted. Values of
+// flags implementing SchemaDescriber add their constraints. Unless the
+// unknown key policy allows them, other properties are not allowed.
+func WriteJSONSchema(w io.Writer) error {
+	return defaultSet.WriteJSONSchema(w)
+}
+
+// WriteJSONSchema writes to w a JSON Schema describing configuration
+// files. See the package-level WriteJSONSchema.
+func (fs *FlagSet) WriteJSONSchema(w io.Writer) error {
+	root := fs.newSchemaObject()
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	if name := fs.ProgName(); name != "" {
+		root["title"] = name
+	}
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if fs.hidden[f.Name] || err != nil {
+			return
+		}
+		obj := root
+		parts := strings.Split(f.Name, ".")
+		for i, part := range parts[:len(parts)-1] {
+			if table := strings.Join(parts[:i+1], "."); fs.FlagSet.Lookup(table) != nil && !fs.hidden[table] {
+				err = fmt.Errorf("conflag: key of flag -%s is the name of the table of flag -%s", table, f.Name)
+				return
+			}
+			props := obj["properties"].(map[string]interface{})
+			sub, ok := props[part].(map[string]interface{})
+			if !ok || sub["type"] != "object" {
+				sub = fs.newSchemaObject()
+				props[part] = sub
+			}
+			obj = sub
+		}
+		obj["properties"].(map[string]interface{})[parts[len(parts)-1]] = fs.flagSchema(f)
+	})
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// newSchemaObject returns the schema of an object without properties.
+func (fs *FlagSet) newSchemaObject() map[string]interface{} {
+	obj := map[string]interface{}{
+		"type":       "object",
+		"properties": make(map[string]interface{}),
+	}
+	if fs.root().unknownKeyPolicy == UnknownKeyError {
+		obj["additionalProperties"] = false
+	}
+	return obj
+}
+
+// flagSchema returns the schema of the flag's value.
+func (fs *FlagSet) flagSchema(f *flag.Flag) map[string]interface{} {
+	s := make(map[string]interface{})
+	if _, usage := unquoteUsage(f); usage != "" {
+		s["description"] = usage
+	}
+	var v interface{}
+	if g, ok := f.Value.(flag.Getter); ok {
+		v = g.Get()
+	}
+	var def interface{} = f.DefValue
+	switch v.(type) {
+	case bool:
+		s["type"] = "boolean"
+		def, _ = strconv.ParseBool(f.DefValue)
+	case int, int64:
+		s["type"] = "integer"
+		def, _ = strconv.ParseInt(f.DefValue, 0, 64)
+	case uint, uint64:
+		s["type"] = "integer"
+		s["minimum"] = 0
+		def, _ = strconv.ParseUint(f.DefValue, 0, 64)
+	case float64:
+		s["type"] = "number"
+		def, _ = strconv.ParseFloat(f.DefValue, 64)
+	default:
+		s["type"] = "string"
+	}
+	if _, ok := f.Value.(listValue); ok {
+		item := "string"
+		if _, ok := v.([]int); ok {
+			item = "integer"
+		}
+		s["type"] = []string{"string", "array"}
+		s["items"] = map[string]interface{}{"type": item}
+	}
+	if fs.IsSensitive(f.Name) {
+		s["writeOnly"] = true
+	} else {
+		s["default"] = def
+	}
+	if d, ok := f.Value.(SchemaDescriber); ok {
+		for k, v := range d.JSONSchema() {
+			s[k] = v
+		}
+	}
+	return s
+}
@@ -0,0 +1,217 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// SetSearchPaths sets the paths of configuration files, which are loaded
+// in the given order instead of /etc/progname and $HOME/.progname. A
+// leading "~" in a path is replaced with the home directory. As with the
+// default paths, the file may be either at the path itself or at the path
+// with an appended extension of a known format, which is detected. Calling
+// SetSearchPaths without arguments disables configuration files.
+//
+// The PROGNAME_CONFIG_PATH environment variable, if set, overrides the
+// paths. It lists them separated by filepath.ListSeparator (colon on
+// Unix), in the order of loading, so that files later in the list
+// override earlier ones:
+//
+//	MYCMD_CONFIG_PATH=/etc/mycmd:/srv/cfg/mycmd:~/.mycmd
+func SetSearchPaths(paths ...string) {
+	defaultSet.SetSearchPaths(paths...)
+}
+
+// SetSearchPaths sets the paths of configuration files. See the
+// package-level SetSearchPaths.
+func (fs *FlagSet) SetSearchPaths(paths ...string) {
+	fs.root().searchPaths = append([]string{}, paths...)
+}
+
+// searchPathList returns the paths of configuration files from the
+// PROGNAME_CONFIG_PATH environment variable or set with SetSearchPaths,
+// or nil if the default paths should be used.
+func (fs *FlagSet) searchPathList() []string {
+	root := fs.root()
+	if root.progName != "" {
+		if v := os.Getenv(root.envName("CONFIG_PATH")); v != "" {
+			paths := []string{}
+			for _, path := range filepath.SplitList(v) {
+				if path != "" {
+					paths = append(paths, path)
+				}
+			}
+			return paths
+		}
+	}
+	return root.searchPaths
+}
+
+// ConfigFilesUsed returns the paths of configuration files, including the
+// .env file, that were loaded by Parse, in the order of loading.
+func ConfigFilesUsed() []string {
+	return defaultSet.ConfigFilesUsed()
+}
+
+// ConfigFilesUsed returns the paths of configuration files that were
+// loaded by Parse.
+func (fs *FlagSet) ConfigFilesUsed() []string {
+	return append([]string(nil), fs.root().filesUsed...)
+}
+
+// markUsed records that the configuration file was loaded.
+func (fs *FlagSet) markUsed(filename string) {
+	fs.event(slog.LevelDebug, "config file read", slog.String("file", filename))
+	root := fs.root()
+	for _, name := range root.filesUsed {
+		if name == filename {
+			return
+		}
+	}
+	root.filesUsed = append(root.filesUsed, filename)
+}
+
+// DisableGlobalConfig disables loading of the global configuration file,
+// /etc/progname, while the user configuration file is still loaded.
+// It has no effect on paths set with SetSearchPaths.
+func DisableGlobalConfig() {
+	defaultSet.DisableGlobalConfig()
+}
+
+// DisableGlobalConfig disables loading of the global configuration file.
+func (fs *FlagSet) DisableGlobalConfig() {
+	fs.root().noGlobalConfig = true
+}
+
+// DisableUserConfig disables loading of the user configuration file,
+// $HOME/.progname, while the global configuration file is still loaded.
+// It has no effect on paths set with SetSearchPaths.
+func DisableUserConfig() {
+	defaultSet.DisableUserConfig()
+}
+
+// DisableUserConfig disables loading of the user configuration file.
+func (fs *FlagSet) DisableUserConfig() {
+	fs.root().noUserConfig = true
+}
+
+// EnableNoConfigFlag defines a boolean flag with the given name, such as
+// "no-config", which makes Parse skip loading of all configuration files.
+// Setting the PROGNAME_NO_CONFIG environment variable to a true value,
+// such as 1, has the same effect. This allows running the program when a
+// broken configuration file prevents it from starting.
+func EnableNoConfigFlag(name string) {
+	defaultSet.EnableNoConfigFlag(name)
+}
+
+// EnableNoConfigFlag defines a boolean flag with the given name, which
+// makes Parse skip loading of configuration files. It must be called on
+// the top-level flag set.
+func (fs *FlagSet) EnableNoConfigFlag(name string) {
+	fs.Bool(name, false, "don't load configuration files")
+	fs.noConfigFlag = name
+}
+
+// SetConfigEnv sets the name of the environment variable listing paths
+// of configuration files, which is PROGNAME_CONFIG by default, such as
+// MYCMD_CONFIG for program name "mycmd". The paths are separated by
+// filepath.ListSeparator (colon on Unix) and replace the default global
+// and user configuration files, unless the list contains an empty
+// element, such as a trailing separator, in which case the files are
+// loaded after the default ones:
+//
+//	MYCMD_CONFIG=/run/secrets/mycmd.conf:/etc/mycmd/extra.conf
+//	MYCMD_CONFIG=/etc/mycmd/extra.conf:
+//
+// Paths may omit the extension, as with SetSearchPaths. The file named by
+// the flag enabled with EnableConfigFlag takes precedence over the
+// variable. An empty name restores the default.
+func SetConfigEnv(name string) {
+	defaultSet.SetConfigEnv(name)
+}
+
+// SetConfigEnv sets the name of the environment variable listing paths
+// of configuration files. See the package-level SetConfigEnv.
+func (fs *FlagSet) SetConfigEnv(name string) {
+	fs.root().configEnv = name
+}
+
+// configEnvPaths returns paths listed in the environment variable set
+// with SetConfigEnv, and reports whether they extend the default paths.
+func (fs *FlagSet) configEnvPaths() (paths []string, extend bool) {
+	root := fs.root()
+	name := root.configEnv
+	if name == "" {
+		if root.progName == "" {
+			return nil, false
+		}
+		name = root.envName("CONFIG")
+	}
+	v := os.Getenv(name)
+	if v == "" {
+		return nil, false
+	}
+	for _, path := range filepath.SplitList(v) {
+		if path == "" {
+			extend = true
+		} else {
+			paths = append(paths, path)
+		}
+	}
+	return paths, extend
+}
+
+// EnableConfigFlag defines a string flag with the given name, such as
+// "config", which names the configuration file to load instead of the
+// user configuration file. The name "-" makes Parse read configuration
+// from the standard input, so that another program can pipe generated
+// configuration into the process without writing it to disk.
+func EnableConfigFlag(name string) {
+	defaultSet.EnableConfigFlag(name)
+}
+
+// EnableConfigFlag defines a string flag with the given name, which names
+// the configuration file to load instead of the user configuration file.
+// It must be called on the top-level flag set.
+func (fs *FlagSet) EnableConfigFlag(name string) {
+	fs.String(name, "", "load configuration from `file` (- for standard input)")
+	fs.configFlag = name
+}
+
+// configFileArg returns the configuration file named by the flag enabled
+// with EnableConfigFlag.
+func (fs *FlagSet) configFileArg() (string, bool) {
+	root := fs.root()
+	if root.configFlag == "" {
+		return "", false
+	}
+	path, ok := root.lookupArg(root.arguments, root.configFlag)
+	return path, ok && path != ""
+}
+
+// configDisabled reports whether loading of configuration files is
+// disabled by the flag enabled with EnableNoConfigFlag or by the
+// environment.
+func (fs *FlagSet) configDisabled() bool {
+	root := fs.root()
+	if root.progName != "" {
+		if b, _ := strconv.ParseBool(os.Getenv(root.envName("SKIP_CONFIG"))); b {
+			return true
+		}
+	}
+	if root.noConfigFlag == "" {
+		return false
+	}
+	if v, ok := root.lookupArg(root.arguments, root.noConfigFlag); ok {
+		b, err := strconv.ParseBool(v)
+		return v == "" || err == nil && b
+	}
+	b, _ := strconv.ParseBool(os.Getenv(root.envName("NO_CONFIG")))
+	return b
+}
@@ -0,0 +1,201 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// parseSectionHeader returns the section name from the header line,
+// such as "[profile production]", with whitespace normalized.
+func parseSectionHeader(line string) (string, error) {
+	if !strings.HasSuffix(line, "]") {
+		return "", fmt.Errorf("bad section header: %s", line)
+	}
+	section := strings.Join(strings.Fields(line[1:len(line)-1]), " ")
+	if section == "" {
+		return "", fmt.Errorf("bad section header: %s", line)
+	}
+	return section, nil
+}
+
+// Ranks of sections: entries from sections with higher ranks override
+// entries from sections with lower ranks.
+const (
+	rankTopLevel = iota
+	rankCommand
+	rankProfile
+	rankHost
+)
+
+// sectionRank returns the rank of the section and whether its entries
+// apply to this run of the program.
+func (fs *FlagSet) sectionRank(section string) (rank int, ok bool, err error) {
+	if section == "" {
+		return rankTopLevel, true, nil
+	}
+	if isPrefixSection(section) {
+		return rankTopLevel, true, nil
+	}
+	kind, arg, _ := strings.Cut(section, " ")
+	switch kind {
+	case "command":
+		if arg == "" {
+			return 0, false, fmt.Errorf("missing command name in section [%s]", section)
+		}
+		return rankCommand, arg == fs.command, nil
+	case "profile":
+		if arg == "" {
+			return 0, false, fmt.Errorf("missing profile name in section [%s]", section)
+		}
+		return rankProfile, arg == fs.Profile(), nil
+	case "host":
+		if arg == "" {
+			return 0, false, fmt.Errorf("missing host name in section [%s]", section)
+		}
+		ok, err := matchHostname(arg)
+		if err != nil {
+			return 0, false, fmt.Errorf("bad host pattern in section [%s]: %s", section, err)
+		}
+		return rankHost, ok, nil
+	}
+	return 0, false, fmt.Errorf("unknown section [%s]", section)
+}
+
+// isPrefixSection reports whether the section, such as "server", prefixes
+// keys of its entries rather than selecting when they are applied.
+func isPrefixSection(section string) bool {
+	switch section {
+	case "", "command", "profile", "host":
+		return false
+	}
+	return !strings.Contains(section, " ")
+}
+
+// selectSections returns the entries that apply to this run of the
+// program, ordered by section rank.
+func (fs *FlagSet) selectSections(entries []entry) ([]entry, error) {
+	type rankedEntry struct {
+		entry
+		rank int
+	}
+	var ranked []rankedEntry
+	for _, e := range entries {
+		rank, ok, err := fs.sectionRank(e.section)
+		if err != nil {
+			return nil, e.errorf("%s", err)
+		}
+		if !ok {
+			fs.tracef("%s: skip key %s in inactive section [%s]", e.location(), e.name, e.section)
+			continue
+		}
+		if isPrefixSection(e.section) {
+			e.name = e.section + "." + e.name
+		}
+		ranked = append(ranked, rankedEntry{e, rank})
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].rank < ranked[j].rank })
+	selected := make([]entry, len(ranked))
+	for i, r := range ranked {
+		selected[i] = r.entry
+	}
+	return selected, nil
+}
+
+// SetProfile selects the configuration profile, whose sections in
+// configuration files are applied. The profile given in the flag enabled
+// by EnableProfileFlag or in the PROGNAME_PROFILE environment variable
+// takes precedence.
+func SetProfile(name string) {
+	defaultSet.SetProfile(name)
+}
+
+// SetProfile selects the configuration profile. See the package-level
+// SetProfile.
+func (fs *FlagSet) SetProfile(name string) {
+	fs.root().profile = name
+}
+
+// EnableProfileFlag defines a string flag with the given name, such as
+// "profile", which selects the configuration profile.
+func EnableProfileFlag(name string) {
+	defaultSet.EnableProfileFlag(name)
+}
+
+// EnableProfileFlag defines a string flag with the given name, which
+// selects the configuration profile. It must be called on the top-level
+// flag set.
+func (fs *FlagSet) EnableProfileFlag(name string) {
+	fs.String(name, "", "configuration profile `name`")
+	fs.profileFlag = name
+}
+
+// Profile returns the name of the selected configuration profile,
+// or an empty string if no profile is selected.
+func Profile() string {
+	return defaultSet.Profile()
+}
+
+// Profile returns the name of the selected configuration profile,
+// or an empty string if no profile is selected.
+func (fs *FlagSet) Profile() string {
+	root := fs.root()
+	if root.profileFlag != "" {
+		if v, ok := root.lookupArg(root.arguments, root.profileFlag); ok {
+			return v
+		}
+	}
+	if root.progName != "" {
+		if v := os.Getenv(root.envName("PROFILE")); v != "" {
+			return v
+		}
+	}
+	return root.profile
+}
+
+// matchHostname reports whether the host name of the machine, or its
+// first component, matches the glob pattern.
+func matchHostname(pattern string) (bool, error) {
+	name, err := os.Hostname()
+	if err != nil {
+		return false, nil
+	}
+	short, _, _ := strings.Cut(name, ".")
+	for _, n := range []string{name, short} {
+		if ok, err := path.Match(strings.ToLower(pattern), strings.ToLower(n)); ok || err != nil {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// lookupArg returns the last value of the named flag in the command-line
+// arguments without parsing them.
+func (fs *FlagSet) lookupArg(arguments []string, name string) (value string, ok bool) {
+	for i := 0; i < len(arguments); i++ {
+		s := arguments[i]
+		if len(s) < 2 || s[0] != '-' || s == "--" {
+			break
+		}
+		s = strings.TrimPrefix(s[1:], "-")
+		n, v, hasValue := strings.Cut(s, "=")
+		f := fs.Lookup(n)
+		if f == nil {
+			continue
+		}
+		if !hasValue && !isBoolFlag(f) && i+1 < len(arguments) {
+			i++
+			v = arguments[i]
+		}
+		if f.Name == name {
+			value, ok = v, true
+		}
+	}
+	return
+}
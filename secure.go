@@ -0,0 +1,48 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"io"
+	"os"
+)
+
+// RequireSecureConfig makes Parse refuse to load configuration files and
+// .env files that are writable by others or owned by a user other than
+// root or the current user, like ssh does for its configuration, so that
+// other users can't change the configuration of programs that read
+// credentials or run with elevated privileges. Such files are handled as
+// unreadable files according to SetUnreadableFilePolicy. The policy has
+// effect only on Unix systems.
+func RequireSecureConfig() {
+	defaultSet.RequireSecureConfig()
+}
+
+// RequireSecureConfig makes Parse refuse to load insecure configuration
+// files. See the package-level RequireSecureConfig.
+func (fs *FlagSet) RequireSecureConfig() {
+	fs.root().secureConfig = true
+}
+
+// readFile returns the contents of the file, checking its ownership and
+// permissions if required by RequireSecureConfig.
+func (fs *FlagSet) readFile(filename string) ([]byte, error) {
+	if !fs.root().secureConfig {
+		return os.ReadFile(filename)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSecure(fi); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(f)
+}
@@ -0,0 +1,14 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !unix
+
+package conflag
+
+import "os"
+
+// checkSecure does nothing on systems other than Unix.
+func checkSecure(fi os.FileInfo) error {
+	return nil
+}
@@ -0,0 +1,28 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build unix
+
+package conflag
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkSecure returns an error if the file is writable by others or owned
+// by a user other than root or the current user.
+func checkSecure(fi os.FileInfo) error {
+	if fi.Mode().Perm()&0o002 != 0 {
+		return errors.New("file is writable by others")
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		if uid := int(st.Uid); uid != 0 && uid != os.Getuid() {
+			return fmt.Errorf("file is owned by another user (uid %d)", uid)
+		}
+	}
+	return nil
+}
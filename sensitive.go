@@ -0,0 +1,53 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"flag"
+	"fmt"
+)
+
+// mask replaces values of sensitive flags in any output.
+const mask = "*****"
+
+// MarkSensitive marks the named flags as holding secrets, such as
+// passwords or API keys. Values of sensitive flags are replaced with
+// "*****" in PrintDefaults and in every report produced by this package.
+// The flags, which may be referred to by aliases, must be defined.
+func MarkSensitive(names ...string) {
+	defaultSet.MarkSensitive(names...)
+}
+
+// MarkSensitive marks the named flags as holding secrets.
+// See the package-level MarkSensitive.
+func (fs *FlagSet) MarkSensitive(names ...string) {
+	for _, name := range names {
+		name = fs.canonicalName(name)
+		if fs.FlagSet.Lookup(name) == nil {
+			panic(fmt.Sprintf("conflag: marking undefined flag %s as sensitive", name))
+		}
+		fs.sensitive[name] = true
+	}
+}
+
+// IsSensitive reports whether the named flag, or the flag the named
+// alias refers to, was marked as sensitive.
+func IsSensitive(name string) bool {
+	return defaultSet.IsSensitive(name)
+}
+
+// IsSensitive reports whether the named flag was marked as sensitive.
+func (fs *FlagSet) IsSensitive(name string) bool {
+	return fs.sensitive[fs.canonicalName(name)]
+}
+
+// displayValue returns the current value of the flag suitable for
+// displaying, masking it if the flag is sensitive.
+func (fs *FlagSet) displayValue(f *flag.Flag) string {
+	if fs.IsSensitive(f.Name) {
+		return mask
+	}
+	return f.Value.String()
+}
@@ -0,0 +1,66 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+)
+
+// SetSlogLogger makes the flag set emit structured records about
+// configuration activity to the logger:
+//
+//   - "config file read" at slog.LevelDebug for each loaded file, with
+//     the attribute "file";
+//   - "flag set" at slog.LevelDebug for each flag value set, or "flag
+//     overridden" if the value replaces one from another source, with the
+//     attributes "flag", "value", "source" and, for overrides, "previous";
+//     changes made through AdminHandler are logged at slog.LevelInfo;
+//   - messages passed to the logger set with SetLogger, at slog.LevelDebug
+//     or slog.LevelWarn, if no such logger is set;
+//   - "configuration error" at slog.LevelError for errors that stop
+//     parsing, with the attribute "error".
+//
+// Records of subcommand flag sets have the attribute "command". Values of
+// sensitive flags are masked. Passing nil disables structured records.
+func SetSlogLogger(logger *slog.Logger) {
+	defaultSet.SetSlogLogger(logger)
+}
+
+// SetSlogLogger makes the flag set emit structured records about
+// configuration activity to the logger. See the package-level
+// SetSlogLogger.
+func (fs *FlagSet) SetSlogLogger(logger *slog.Logger) {
+	fs.root().slogger = logger
+}
+
+// event emits a structured record to the logger set with SetSlogLogger,
+// if any.
+func (fs *FlagSet) event(level slog.Level, msg string, args ...any) {
+	logger := fs.root().slogger
+	if logger == nil {
+		return
+	}
+	if fs.command != "" {
+		args = append(args, slog.String("command", fs.command))
+	}
+	logger.Log(context.Background(), level, msg, args...)
+}
+
+// setEvent emits a structured record about the flag set from source,
+// replacing the value from oldSource.
+func (fs *FlagSet) setEvent(f *flag.Flag, source, oldSource string) {
+	level, msg := slog.LevelDebug, "flag set"
+	if source == SourceRuntime {
+		level = slog.LevelInfo
+	}
+	args := []any{slog.String("flag", f.Name), slog.String("value", fs.displayValue(f)), slog.String("source", source)}
+	if oldSource != SourceDefault && oldSource != source {
+		msg = "flag overridden"
+		args = append(args, slog.String("previous", oldSource))
+	}
+	fs.event(level, msg, args...)
+}
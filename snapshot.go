@@ -0,0 +1,105 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+)
+
+// State holds the values of flags captured by Snapshot.
+type State struct {
+	values   map[string]reflect.Value // copies of variables pointed to by flag values
+	strings  map[string]string        // string forms of other flag values
+	sources  map[string]string
+	commands map[string]State
+}
+
+// Snapshot captures the current values of all flags and their sources,
+// including flags of commands, so that they can be restored with Restore.
+// This allows tests and request-scoped overrides to change flags
+// temporarily.
+func Snapshot() State {
+	return defaultSet.Snapshot()
+}
+
+// Snapshot captures the current values of all flags and their sources.
+// See the package-level Snapshot.
+func (fs *FlagSet) Snapshot() State {
+	runtimeMu.RLock()
+	defer runtimeMu.RUnlock()
+	return fs.snapshot()
+}
+
+func (fs *FlagSet) snapshot() State {
+	st := State{
+		values:   make(map[string]reflect.Value),
+		strings:  make(map[string]string),
+		sources:  make(map[string]string),
+		commands: make(map[string]State),
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		// Values of flag types are usually pointers to variables, which
+		// are copied to restore them exactly, even if setting the value
+		// from its string form would, for example, append to a list.
+		if v := reflect.ValueOf(f.Value); v.Kind() == reflect.Pointer && !v.IsNil() {
+			c := reflect.New(v.Elem().Type()).Elem()
+			c.Set(v.Elem())
+			st.values[f.Name] = c
+		} else {
+			st.strings[f.Name] = f.Value.String()
+		}
+	})
+	for name, source := range fs.sources {
+		st.sources[name] = source
+	}
+	for name, c := range fs.commands {
+		st.commands[name] = c.snapshot()
+	}
+	return st
+}
+
+// Restore restores the values of flags and their sources captured by
+// Snapshot. Values that aren't pointers are restored by setting them
+// from their string form if it differs from the current one. Copies of
+// variables made by Snapshot are shallow, so values that change data
+// referenced by their variables, such as maps, in place are not restored.
+func Restore(st State) error {
+	return defaultSet.Restore(st)
+}
+
+// Restore restores the values of flags and their sources captured by
+// Snapshot. See the package-level Restore.
+func (fs *FlagSet) Restore(st State) error {
+	runtimeMu.Lock()
+	defer runtimeMu.Unlock()
+	return fs.restore(st)
+}
+
+func (fs *FlagSet) restore(st State) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if c, ok := st.values[f.Name]; ok {
+			reflect.ValueOf(f.Value).Elem().Set(c)
+		} else if s, ok := st.strings[f.Name]; ok && s != f.Value.String() {
+			if serr := f.Value.Set(s); serr != nil && err == nil {
+				err = fmt.Errorf("conflag: can't restore flag -%s: %v", f.Name, serr)
+			}
+		}
+	})
+	fs.sources = make(map[string]string)
+	for name, source := range st.sources {
+		fs.sources[name] = source
+	}
+	for name, c := range fs.commands {
+		if cst, ok := st.commands[name]; ok {
+			if cerr := c.restore(cst); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,169 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// Sources of flag values other than configuration files, as returned by
+// Source.
+const (
+	SourceDefault     = "default"
+	SourceCommandLine = "command line"
+	SourceRuntime     = "runtime" // set through AdminHandler
+)
+
+// Source returns the source of the current value of the named flag:
+// the path of the configuration file it was read from, the name of the
+// environment variable preceded by "$", the keyring item for BindKeyring,
+// SourceCommandLine, SourceRuntime, or SourceDefault if the flag wasn't set.
+func Source(name string) string {
+	return defaultSet.Source(name)
+}
+
+// Source returns the source of the current value of the named flag.
+func (fs *FlagSet) Source(name string) string {
+	if s, ok := fs.sources[fs.canonicalName(name)]; ok {
+		return s
+	}
+	return SourceDefault
+}
+
+// IsSet reports whether the named flag was set from any source, such as
+// a configuration file, an environment variable, or the command line,
+// rather than left at its default value. Values from the default
+// configuration set with SetDefaultConfig don't count as set.
+func IsSet(name string) bool {
+	return defaultSet.IsSet(name)
+}
+
+// IsSet reports whether the named flag was set from any source.
+func (fs *FlagSet) IsSet(name string) bool {
+	s, ok := fs.sources[fs.canonicalName(name)]
+	return ok && s != defaultConfigName
+}
+
+// sourceValue forwards Set to the flag of the flag set,
+// recording the source of the value.
+type sourceValue struct {
+	fs     *FlagSet
+	f      *flag.Flag
+	name   string // name used to set the flag, possibly an alias
+	source string
+}
+
+func (v *sourceValue) String() string {
+	if v.f == nil {
+		return ""
+	}
+	return v.f.Value.String()
+}
+
+func (v *sourceValue) Set(s string) error {
+	return v.fs.setFlag(v.f, v.name, s, v.source)
+}
+
+func (v *sourceValue) IsBoolFlag() bool {
+	return isBoolFlag(v.f)
+}
+
+// isBoolFlag reports whether the flag doesn't require a value,
+// like boolean flags.
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// setFlag sets the value of the flag, recording the source of the value.
+// The name is the one used to refer to the flag, possibly an alias.
+func (fs *FlagSet) setFlag(f *flag.Flag, name, value, source string) error {
+	old, oldSource := fs.traceValue(f.Name, f.Value.String()), fs.Source(f.Name)
+	if l, ok := f.Value.(listValue); ok {
+		if source != oldSource && (oldSource == SourceDefault || fs.listModes[f.Name] != ListAppend) {
+			l.resetList()
+		}
+		value = fs.listElems(f.Name, value)
+	}
+	if err := fs.FlagSet.Set(f.Name, value); err != nil {
+		return err
+	}
+	fs.tracef("%s: set -%s=%s, overriding %s from %s", source, f.Name, fs.traceValue(f.Name, f.Value.String()), old, oldSource)
+	fs.setEvent(f, source, oldSource)
+	fs.sources[f.Name] = source
+	delete(fs.pendingRefs, f.Name)
+	fs.warnDeprecated(name, source)
+	return nil
+}
+
+// parseArgs parses arguments into the flag set, recording source as
+// the source of every flag it sets, and returns the remaining non-flag
+// arguments. Errors are handled according to the error handling property
+// of the flag set.
+func (fs *FlagSet) parseArgs(arguments []string, source string) ([]string, error) {
+	if source == SourceCommandLine {
+		if err := fs.checkArgs(arguments); err != nil {
+			return nil, fs.failConfig(err)
+		}
+	}
+	m := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	m.SetOutput(fs.Output())
+	m.Usage = fs.Usage
+	if m.Usage == nil {
+		m.Usage = fs.defaultUsage
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		m.Var(&sourceValue{fs, f, f.Name, source}, f.Name, f.Usage)
+	})
+	for alias, name := range fs.aliases {
+		f := fs.FlagSet.Lookup(name)
+		m.Var(&sourceValue{fs, f, alias, source}, alias, f.Usage)
+	}
+	if fs.root().negation && source == SourceCommandLine {
+		fs.VisitAll(func(f *flag.Flag) {
+			if name := "no-" + f.Name; isBoolFlag(f) && m.Lookup(name) == nil {
+				m.Var(negatedValue{&sourceValue{fs, f, name, source}}, name, f.Usage)
+			}
+		})
+	}
+	if err := m.Parse(arguments); err != nil {
+		if err != flag.ErrHelp {
+			fs.event(slog.LevelError, "configuration error", slog.Any("error", err))
+		}
+		return nil, fs.handleError(err)
+	}
+	return m.Args(), nil
+}
+
+// failConfig prints the configuration error followed by usage, and
+// handles it according to the error handling property of the flag set.
+func (fs *FlagSet) failConfig(err error) error {
+	fs.event(slog.LevelError, "configuration error", slog.Any("error", err))
+	fmt.Fprintln(fs.Output(), err)
+	if fs.Usage != nil {
+		fs.Usage()
+	} else {
+		fs.defaultUsage()
+	}
+	return fs.handleError(err)
+}
+
+// handleError handles the error according to the error handling property
+// of the flag set, returning it in case of flag.ContinueOnError.
+func (fs *FlagSet) handleError(err error) error {
+	switch fs.ErrorHandling() {
+	case flag.ExitOnError:
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	case flag.PanicOnError:
+		panic(err)
+	}
+	return err
+}
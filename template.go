@@ -0,0 +1,126 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// UsageData is the data passed to the usage template set with
+// SetUsageTemplate.
+type UsageData struct {
+	Name        string            // name of the flag set
+	ProgName    string            // program name set with SetProgName
+	Command     string            // command name, empty for the top-level set
+	Commands    []string          // names of commands in lexicographical order
+	Groups      []UsageGroup      // visible flags, ungrouped first
+	ConfigFiles []UsageConfigFile // configuration files in the order of loading
+}
+
+// UsageGroup is a group of flags defined with Group. The group of
+// ungrouped flags has an empty title.
+type UsageGroup struct {
+	Title string
+	Flags []UsageFlag
+}
+
+// UsageFlag describes a flag for the usage template.
+type UsageFlag struct {
+	Name        string
+	Aliases     []string // non-deprecated aliases
+	ValueName   string   // name of the value, such as "string", empty for boolean flags
+	Usage       string   // usage string with the back quotes of the value name removed
+	Default     string   // default value, masked for sensitive flags
+	HasDefault  bool     // whether the default value differs from the zero value
+	Sensitive   bool
+	Annotations map[string]string // annotations set with Annotate
+}
+
+// UsageConfigFile describes a configuration file for the usage template.
+type UsageConfigFile struct {
+	Path  string
+	Found bool
+}
+
+// SetUsageTemplate sets the usage function of the default set to one that
+// executes the text/template with UsageData and writes the result to the
+// output of the flag set. In addition to the standard functions, the
+// template can call "join", which is strings.Join. For example:
+//
+//	flag.SetUsageTemplate(`Usage: {{.ProgName}} [flags]
+//	{{range .Groups}}{{if .Title}}
+//	{{.Title}}:{{end}}{{range .Flags}}
+//	  -{{.Name}}{{if .ValueName}} {{.ValueName}}{{end}}	{{.Usage}}{{end}}
+//	{{end}}`)
+//
+// SetUsageTemplate panics if the template can't be parsed.
+func SetUsageTemplate(text string) {
+	defaultSet.SetUsageTemplate(text)
+}
+
+// SetUsageTemplate sets the usage function of the flag set to one that
+// executes the template. See the package-level SetUsageTemplate.
+func (fs *FlagSet) SetUsageTemplate(text string) {
+	t, err := template.New("usage").Funcs(template.FuncMap{"join": strings.Join}).Parse(text)
+	if err != nil {
+		panic("conflag: " + err.Error())
+	}
+	fs.Usage = func() {
+		if err := t.Execute(fs.Output(), fs.usageData()); err != nil {
+			fmt.Fprintf(fs.Output(), "conflag: usage template: %s\n", err)
+		}
+	}
+}
+
+// usageData returns the data for the usage template.
+func (fs *FlagSet) usageData() *UsageData {
+	d := &UsageData{
+		Name:     fs.Name(),
+		ProgName: fs.ProgName(),
+		Command:  fs.command,
+		Commands: fs.Commands(),
+	}
+	for _, g := range fs.flagGroups() {
+		ug := UsageGroup{Title: g.title}
+		for _, f := range g.flags {
+			ug.Flags = append(ug.Flags, fs.usageFlag(f))
+		}
+		d.Groups = append(d.Groups, ug)
+	}
+	paths, _ := fs.configFilePaths()
+	for _, path := range paths {
+		if path == "-" {
+			d.ConfigFiles = append(d.ConfigFiles, UsageConfigFile{stdinName, true})
+			continue
+		}
+		_, err := os.Stat(path)
+		d.ConfigFiles = append(d.ConfigFiles, UsageConfigFile{path, err == nil})
+	}
+	return d
+}
+
+// usageFlag returns the description of the flag for the usage template.
+func (fs *FlagSet) usageFlag(f *flag.Flag) UsageFlag {
+	name, usage := unquoteUsage(f)
+	isZero, err := isZeroValue(f, f.DefValue)
+	uf := UsageFlag{
+		Name:        f.Name,
+		Aliases:     fs.aliasesOf(f.Name),
+		ValueName:   name,
+		Usage:       usage,
+		Default:     f.DefValue,
+		HasDefault:  err == nil && !isZero,
+		Sensitive:   fs.IsSensitive(f.Name),
+		Annotations: fs.Annotations(f.Name),
+	}
+	if uf.Sensitive {
+		uf.Default = mask
+	}
+	return uf
+}
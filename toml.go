@@ -0,0 +1,465 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// decodeTOML returns entries of the TOML document. Keys in tables and
+// dotted keys are mapped to dotted flag names, so that "port" in the
+// "[server]" table sets the flag "server.port". Each element of an array
+// is a separate entry for the same flag, as if the flag was repeated on
+// the command line. Arrays of tables are not supported.
+func decodeTOML(filename string, data []byte) ([]entry, error) {
+	p := &tomlParser{s: strings.TrimPrefix(string(data), utf8BOM), file: filename, line: 1}
+	if err := p.parse(); err != nil {
+		return nil, fmt.Errorf("%s:%d: %s", filename, p.line, err)
+	}
+	return p.entries, nil
+}
+
+type tomlParser struct {
+	s       string
+	pos     int
+	line    int
+	file    string
+	entries []entry
+}
+
+func (p *tomlParser) eof() bool { return p.pos >= len(p.s) }
+
+func (p *tomlParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+// skipSpace skips spaces and tabs.
+func (p *tomlParser) skipSpace() {
+	for !p.eof() && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// skipComment skips the comment up to the end of line.
+func (p *tomlParser) skipComment() {
+	if p.peek() == '#' {
+		for !p.eof() && p.s[p.pos] != '\n' {
+			p.pos++
+		}
+	}
+}
+
+// skipBlank skips whitespace, comments, and line breaks.
+func (p *tomlParser) skipBlank() {
+	for {
+		p.skipSpace()
+		p.skipComment()
+		switch p.peek() {
+		case '\n':
+			p.line++
+			p.pos++
+		case '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+// endLine consumes the rest of the line, which may contain only
+// whitespace and a comment.
+func (p *tomlParser) endLine() error {
+	p.skipSpace()
+	p.skipComment()
+	if p.peek() == '\r' {
+		p.pos++
+	}
+	switch {
+	case p.eof():
+		return nil
+	case p.peek() == '\n':
+		p.pos++
+		p.line++
+		return nil
+	}
+	return fmt.Errorf("unexpected %q at end of line", p.rest())
+}
+
+// rest returns the rest of the current line for error messages.
+func (p *tomlParser) rest() string {
+	s := p.s[p.pos:]
+	if i := strings.IndexAny(s, "\r\n"); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+func (p *tomlParser) parse() error {
+	prefix := ""
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return nil
+		}
+		if p.peek() == '[' {
+			if strings.HasPrefix(p.s[p.pos:], "[[") {
+				return fmt.Errorf("arrays of tables are not supported")
+			}
+			p.pos++
+			p.skipSpace()
+			key, err := p.key()
+			if err != nil {
+				return err
+			}
+			p.skipSpace()
+			if p.peek() != ']' {
+				return fmt.Errorf("missing ] in table header")
+			}
+			p.pos++
+			prefix = key + "."
+		} else if err := p.keyValue(prefix); err != nil {
+			return err
+		}
+		if err := p.endLine(); err != nil {
+			return err
+		}
+	}
+}
+
+// key parses a possibly dotted key and returns its parts joined by dots.
+func (p *tomlParser) key() (string, error) {
+	var parts []string
+	for {
+		p.skipSpace()
+		var part string
+		switch c := p.peek(); {
+		case c == '"':
+			s, err := p.basicString()
+			if err != nil {
+				return "", err
+			}
+			part = s
+		case c == '\'':
+			s, err := p.literalString()
+			if err != nil {
+				return "", err
+			}
+			part = s
+		default:
+			start := p.pos
+			for !p.eof() && isBareKeyChar(p.s[p.pos]) {
+				p.pos++
+			}
+			if p.pos == start {
+				return "", fmt.Errorf("bad key: %s", p.rest())
+			}
+			part = p.s[start:p.pos]
+		}
+		parts = append(parts, part)
+		p.skipSpace()
+		if p.peek() != '.' {
+			return strings.Join(parts, "."), nil
+		}
+		p.pos++
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '-' || c == '_'
+}
+
+// keyValue parses a key/value pair and adds its entries.
+func (p *tomlParser) keyValue(prefix string) error {
+	line := p.line
+	key, err := p.key()
+	if err != nil {
+		return err
+	}
+	if p.peek() != '=' {
+		return fmt.Errorf("missing = after key %s", key)
+	}
+	p.pos++
+	p.skipSpace()
+	return p.value(prefix+key, line)
+}
+
+// value parses a value and adds its entries for the named flag.
+func (p *tomlParser) value(name string, line int) error {
+	add := func(value string, literal bool) {
+		p.entries = append(p.entries, entry{
+			name:     name,
+			value:    value,
+			hasValue: true,
+			literal:  literal,
+			file:     p.file,
+			line:     line,
+		})
+	}
+	switch p.peek() {
+	case '"':
+		s, err := p.basicString()
+		if err != nil {
+			return err
+		}
+		add(s, false)
+	case '\'':
+		s, err := p.literalString()
+		if err != nil {
+			return err
+		}
+		add(s, true)
+	case '[':
+		p.pos++
+		for {
+			p.skipBlank()
+			if p.peek() == ']' {
+				p.pos++
+				return nil
+			}
+			if c := p.peek(); c == '[' || c == '{' {
+				return fmt.Errorf("nested arrays and tables in arrays are not supported")
+			}
+			if err := p.value(name, line); err != nil {
+				return err
+			}
+			p.skipBlank()
+			switch p.peek() {
+			case ',':
+				p.pos++
+			case ']':
+			default:
+				return fmt.Errorf("missing , or ] in array")
+			}
+		}
+	case '{':
+		p.pos++
+		p.skipSpace()
+		if p.peek() == '}' {
+			p.pos++
+			return nil
+		}
+		for {
+			if err := p.keyValue(name + "."); err != nil {
+				return err
+			}
+			p.skipSpace()
+			switch p.peek() {
+			case ',':
+				p.pos++
+			case '}':
+				p.pos++
+				return nil
+			default:
+				return fmt.Errorf("missing , or } in inline table")
+			}
+		}
+	default:
+		s, err := p.scalar()
+		if err != nil {
+			return err
+		}
+		add(s, true)
+	}
+	return nil
+}
+
+// scalar parses a boolean, number, or date/time value and returns it
+// in the form accepted by flag values.
+func (p *tomlParser) scalar() (string, error) {
+	start := p.pos
+	for !p.eof() && !strings.ContainsRune(" \t\r\n,]}#", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	// Date and time may be separated by a space.
+	if p.pos-start == 10 && p.s[start+4] == '-' && p.pos+1 < len(p.s) &&
+		p.s[p.pos] == ' ' && p.s[p.pos+1] >= '0' && p.s[p.pos+1] <= '9' {
+		p.pos++
+		for !p.eof() && !strings.ContainsRune(" \t\r\n,]}#", rune(p.s[p.pos])) {
+			p.pos++
+		}
+	}
+	s := p.s[start:p.pos]
+	switch {
+	case s == "":
+		return "", fmt.Errorf("missing value")
+	case s == "true" || s == "false":
+		return s, nil
+	case strings.ContainsAny(s, ":T") || len(s) >= 10 && s[4] == '-':
+		// Date, time, or date-time.
+		return s, nil
+	}
+	digits := s
+	if s[0] == '+' || s[0] == '-' {
+		digits = s[1:]
+	}
+	if len(digits) > 1 && digits[0] == '0' {
+		switch digits[1] {
+		case 'x', 'o', 'b':
+			// Unlike Go, TOML doesn't allow signs with prefixes.
+			if n, err := strconv.ParseInt(digits, 0, 64); err == nil && digits == s {
+				return strconv.FormatInt(n, 10), nil
+			}
+			return "", fmt.Errorf("bad value: %s", s)
+		case '.', 'e', 'E':
+		default:
+			// Decimal numbers can't have leading zeros, which would make
+			// them octal in Go.
+			return "", fmt.Errorf("leading zeros in number: %s", s)
+		}
+	}
+	if n, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return strconv.FormatInt(n, 10), nil
+	}
+	if _, err := strconv.ParseFloat(strings.ReplaceAll(s, "_", ""), 64); err == nil {
+		return strings.ReplaceAll(s, "_", ""), nil
+	}
+	return "", fmt.Errorf("bad value: %s", s)
+}
+
+// basicString parses a string in double quotes, possibly multi-line,
+// interpreting escape sequences.
+func (p *tomlParser) basicString() (string, error) {
+	multiline := strings.HasPrefix(p.s[p.pos:], `"""`)
+	if multiline {
+		p.pos += 3
+		p.skipNewline()
+	} else {
+		p.pos++
+	}
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", fmt.Errorf("missing closing quote")
+		}
+		c := p.s[p.pos]
+		switch {
+		case multiline && strings.HasPrefix(p.s[p.pos:], `"""`):
+			p.pos += 3
+			// Up to two quotes may precede the closing delimiter.
+			for i := 0; i < 2 && p.peek() == '"'; i++ {
+				b.WriteByte('"')
+				p.pos++
+			}
+			return b.String(), nil
+		case !multiline && c == '"':
+			p.pos++
+			return b.String(), nil
+		case c == '\n':
+			if !multiline {
+				return "", fmt.Errorf("missing closing quote")
+			}
+			p.line++
+			b.WriteByte(c)
+			p.pos++
+		case c == '\\':
+			p.pos++
+			if err := p.escape(&b, multiline); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// escape interprets the escape sequence following a backslash.
+func (p *tomlParser) escape(b *strings.Builder, multiline bool) error {
+	c := p.peek()
+	p.pos++
+	switch c {
+	case 'b':
+		b.WriteByte('\b')
+	case 't':
+		b.WriteByte('\t')
+	case 'n':
+		b.WriteByte('\n')
+	case 'f':
+		b.WriteByte('\f')
+	case 'r':
+		b.WriteByte('\r')
+	case 'e':
+		b.WriteByte('\x1b')
+	case '"', '\\':
+		b.WriteByte(c)
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if p.pos+n > len(p.s) {
+			return fmt.Errorf("bad escape sequence")
+		}
+		r, err := strconv.ParseUint(p.s[p.pos:p.pos+n], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(r)) {
+			return fmt.Errorf("bad escape sequence \\%c%s", c, p.s[p.pos:p.pos+n])
+		}
+		b.WriteRune(rune(r))
+		p.pos += n
+	case ' ', '\t', '\r', '\n':
+		if !multiline {
+			return fmt.Errorf("bad escape sequence")
+		}
+		// Line-ending backslash trims the following whitespace.
+		p.pos--
+		for !p.eof() && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+			if p.s[p.pos] == '\n' {
+				p.line++
+			}
+			p.pos++
+		}
+	default:
+		return fmt.Errorf("bad escape sequence \\%c", c)
+	}
+	return nil
+}
+
+// literalString parses a string in single quotes, possibly multi-line,
+// taking it literally.
+func (p *tomlParser) literalString() (string, error) {
+	if strings.HasPrefix(p.s[p.pos:], "'''") {
+		p.pos += 3
+		p.skipNewline()
+		end := strings.Index(p.s[p.pos:], "'''")
+		if end < 0 {
+			return "", fmt.Errorf("missing closing quote")
+		}
+		// Up to two quotes may precede the closing delimiter.
+		for i := 0; i < 2 && p.pos+end+3 < len(p.s) && p.s[p.pos+end+3] == '\''; i++ {
+			end++
+		}
+		s := p.s[p.pos : p.pos+end]
+		p.line += strings.Count(s, "\n")
+		p.pos += end + 3
+		return s, nil
+	}
+	p.pos++
+	end := strings.IndexAny(p.s[p.pos:], "'\n")
+	if end < 0 || p.s[p.pos+end] != '\'' {
+		return "", fmt.Errorf("missing closing quote")
+	}
+	s := p.s[p.pos : p.pos+end]
+	p.pos += end + 1
+	return s, nil
+}
+
+// skipNewline skips the line break immediately following the opening
+// delimiter of a multi-line string.
+func (p *tomlParser) skipNewline() {
+	if strings.HasPrefix(p.s[p.pos:], "\r\n") {
+		p.pos += 2
+		p.line++
+	} else if p.peek() == '\n' {
+		p.pos++
+		p.line++
+	}
+}
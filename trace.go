@@ -0,0 +1,45 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"fmt"
+	"io"
+)
+
+// EnableTrace makes Parse write to w every step of resolving the
+// configuration: probed configuration file paths and whether they exist,
+// entries read from each file, skipped entries, and every flag value set
+// with the value it overrides. Values of sensitive flags are masked.
+// Passing nil disables tracing.
+func EnableTrace(w io.Writer) {
+	defaultSet.EnableTrace(w)
+}
+
+// EnableTrace makes Parse write every step of resolving the configuration
+// to w. See the package-level EnableTrace.
+func (fs *FlagSet) EnableTrace(w io.Writer) {
+	fs.root().trace = w
+}
+
+// tracef writes the message to the trace writer, if tracing is enabled.
+func (fs *FlagSet) tracef(format string, args ...interface{}) {
+	if w := fs.root().trace; w != nil {
+		prefix := "conflag: "
+		if fs.command != "" {
+			prefix = "conflag: [" + fs.command + "] "
+		}
+		fmt.Fprintf(w, prefix+format+"\n", args...)
+	}
+}
+
+// traceValue returns the value for tracing, masked if the named flag
+// is sensitive.
+func (fs *FlagSet) traceValue(name, value string) string {
+	if fs.IsSensitive(fs.canonicalName(name)) {
+		return mask
+	}
+	return fmt.Sprintf("%q", value)
+}
@@ -0,0 +1,267 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+)
+
+// Hide hides the named flags from PrintDefaults and WriteExampleConfig.
+// Hidden flags are still accepted from all sources.
+func Hide(names ...string) {
+	defaultSet.Hide(names...)
+}
+
+// Hide hides the named flags from PrintDefaults and WriteExampleConfig.
+func (fs *FlagSet) Hide(names ...string) {
+	for _, name := range names {
+		fs.hidden[name] = true
+	}
+}
+
+// defaultUsage is the default usage function of flag sets.
+func (fs *FlagSet) defaultUsage() {
+	fmt.Fprintf(fs.Output(), "Usage of %s:\n", fs.Name())
+	fs.PrintDefaults()
+	fs.PrintConfigPaths()
+}
+
+// PrintConfigPaths prints to standard error the paths of configuration
+// files in the order they are loaded, noting which of them don't exist.
+// It prints nothing if program name is not set.
+func PrintConfigPaths() {
+	defaultSet.PrintConfigPaths()
+}
+
+// PrintConfigPaths prints, to standard error unless configured otherwise,
+// the paths of configuration files in the order they are loaded.
+func (fs *FlagSet) PrintConfigPaths() {
+	paths, _ := fs.configFilePaths()
+	if len(paths) == 0 {
+		return
+	}
+	w := fs.Output()
+	fmt.Fprintf(w, "Configuration files:\n")
+	for _, path := range paths {
+		if path == "-" {
+			fmt.Fprintf(w, "  %s\n", stdinName)
+		} else if _, err := os.Stat(path); err != nil {
+			fmt.Fprintf(w, "  %s (not found)\n", path)
+		} else {
+			fmt.Fprintf(w, "  %s\n", path)
+		}
+	}
+}
+
+// PrintDefaults prints, to standard error unless configured otherwise,
+// the default values of all defined flags in the set in the same format
+// as flag.PrintDefaults, masking values of sensitive flags and printing
+// grouped flags under group headings. Hidden flags are not printed.
+func (fs *FlagSet) PrintDefaults() {
+	w := fs.Output()
+	var isZeroValueErrs []error
+	for _, g := range fs.flagGroups() {
+		if g.title != "" {
+			fmt.Fprintf(w, "\n%s:\n", g.title)
+		}
+		for _, f := range g.flags {
+			if err := fs.printFlagDefault(w, f); err != nil {
+				isZeroValueErrs = append(isZeroValueErrs, err)
+			}
+		}
+	}
+	// If calling String on any zero flag.Values triggered a panic, print
+	// the messages after the full set of defaults so that the programmer
+	// knows to fix the panic.
+	if errs := isZeroValueErrs; len(errs) > 0 {
+		fmt.Fprintln(w)
+		for _, err := range errs {
+			fmt.Fprintln(w, err)
+		}
+	}
+}
+
+// printFlagDefault writes the usage and default value of a single flag.
+func (fs *FlagSet) printFlagDefault(w io.Writer, f *flag.Flag) (err error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "  -%s", f.Name) // Two spaces before -; see next two comments.
+	for _, alias := range fs.aliasesOf(f.Name) {
+		fmt.Fprintf(&b, ", -%s", alias)
+	}
+	name, usage := unquoteUsage(f)
+	if len(name) > 0 {
+		b.WriteString(" ")
+		b.WriteString(name)
+	}
+	// Boolean flags of one ASCII letter are so common we
+	// treat them specially, putting their usage on the same line.
+	if b.Len() <= 4 { // space, space, '-', 'x'.
+		b.WriteString("\t")
+	} else {
+		// Four spaces before the tab triggers good alignment
+		// for both 4- and 8-space tab stops.
+		b.WriteString("\n    \t")
+	}
+	b.WriteString(strings.ReplaceAll(usage, "\n", "\n    \t"))
+
+	// Print the default value only if it differs to the zero value
+	// for this flag type.
+	if isZero, zerr := isZeroValue(f, f.DefValue); zerr != nil {
+		err = zerr
+	} else if !isZero {
+		switch {
+		case fs.IsSensitive(f.Name):
+			fmt.Fprintf(&b, " (default %s)", mask)
+		case isStringFlag(f):
+			fmt.Fprintf(&b, " (default %q)", f.DefValue)
+		default:
+			fmt.Fprintf(&b, " (default %v)", f.DefValue)
+		}
+	}
+	if envVar, ok := fs.envVars[f.Name]; ok {
+		fmt.Fprintf(&b, " ($%s)", envVar)
+	}
+	fmt.Fprint(w, b.String(), "\n")
+	return
+}
+
+// unquoteUsage is like flag.UnquoteUsage, but also knows the names
+// of flag types defined by this package.
+func unquoteUsage(f *flag.Flag) (name string, usage string) {
+	name, usage = flag.UnquoteUsage(f)
+	if t, ok := f.Value.(interface{ typeName() string }); ok && name == "value" {
+		name = t.typeName()
+	}
+	return
+}
+
+// isZeroValue determines whether the string represents the zero
+// value for a flag.
+func isZeroValue(f *flag.Flag, value string) (ok bool, err error) {
+	// Build a zero value of the flag's Value type, and see if the
+	// result of calling its String method equals the value passed in.
+	// This works unless the Value type is itself an interface type.
+	typ := reflect.TypeOf(f.Value)
+	var z reflect.Value
+	if typ.Kind() == reflect.Pointer {
+		z = reflect.New(typ.Elem())
+	} else {
+		z = reflect.Zero(typ)
+	}
+	// Catch panics calling the String method, which shouldn't prevent the
+	// usage message from being printed, but that we should report to the
+	// user so that they know to fix their code.
+	defer func() {
+		if e := recover(); e != nil {
+			if typ.Kind() == reflect.Pointer {
+				typ = typ.Elem()
+			}
+			err = fmt.Errorf("panic calling String method on zero %v for flag %s: %v", typ, f.Name, e)
+		}
+	}()
+	return value == z.Interface().(flag.Value).String(), nil
+}
+
+// isStringFlag reports whether the flag holds a plain string value.
+func isStringFlag(f *flag.Flag) bool {
+	g, ok := f.Value.(flag.Getter)
+	if !ok {
+		return false
+	}
+	_, ok = g.Get().(string)
+	return ok
+}
+
+// EnableDumpFlag defines a boolean flag with the given name, such as
+// "print-config", which makes Parse print the effective configuration,
+// with the source of each value, to standard output and exit the program.
+// If the flag set doesn't exit on errors, Parse returns flag.ErrHelp
+// instead of exiting.
+func EnableDumpFlag(name string) {
+	defaultSet.EnableDumpFlag(name)
+}
+
+// EnableDumpFlag defines a boolean flag with the given name, which makes
+// Parse print the effective configuration and exit the program.
+func (fs *FlagSet) EnableDumpFlag(name string) {
+	fs.Bool(name, false, "print configuration and exit")
+	fs.dumpFlag = name
+}
+
+// EnableOverridesFlag defines a boolean flag with the given name, such as
+// "show-overrides", which makes Parse print, to standard output, only the
+// flags whose values differ from their defaults, with the source of each
+// value, and exit the program. If the flag set doesn't exit on errors,
+// Parse returns flag.ErrHelp instead of exiting.
+func EnableOverridesFlag(name string) {
+	defaultSet.EnableOverridesFlag(name)
+}
+
+// EnableOverridesFlag defines a boolean flag with the given name, which
+// makes Parse print the flags with non-default values and exit the program.
+func (fs *FlagSet) EnableOverridesFlag(name string) {
+	fs.Bool(name, false, "print flags that differ from defaults and exit")
+	fs.overridesFlag = name
+}
+
+// NonDefault returns flags whose current values differ from their
+// defaults, in lexicographical order. Source reports where the values
+// came from.
+func NonDefault() []*flag.Flag {
+	return defaultSet.NonDefault()
+}
+
+// NonDefault returns flags whose current values differ from their defaults.
+func (fs *FlagSet) NonDefault() []*flag.Flag {
+	var flags []*flag.Flag
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Value.String() != f.DefValue {
+			flags = append(flags, f)
+		}
+	})
+	return flags
+}
+
+// printConfig writes the current value and its source for each flag to w,
+// or only for flags with non-default values if overrides is true.
+func (fs *FlagSet) printConfig(w io.Writer, overrides bool) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == fs.dumpFlag || f.Name == fs.overridesFlag ||
+			overrides && f.Value.String() == f.DefValue {
+			return
+		}
+		fmt.Fprintf(tw, "%s=%s\t(%s)\n", f.Name, fs.displayValue(f), fs.Source(f.Name))
+	})
+	tw.Flush()
+}
+
+// PrintSettings writes to w a table listing, for each flag, its default
+// value, its current value and the source of the current value.
+// Values of sensitive flags are masked.
+func PrintSettings(w io.Writer) error {
+	return defaultSet.PrintSettings(w)
+}
+
+// PrintSettings writes to w a table listing, for each flag, its default
+// value, its current value and the source of the current value.
+func (fs *FlagSet) PrintSettings(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "FLAG\tDEFAULT\tVALUE\tSOURCE")
+	fs.VisitAll(func(f *flag.Flag) {
+		def := f.DefValue
+		if fs.IsSensitive(f.Name) {
+			def = mask
+		}
+		fmt.Fprintf(tw, "-%s\t%s\t%s\t%s\n", f.Name, def, fs.displayValue(f), fs.Source(f.Name))
+	})
+	return tw.Flush()
+}
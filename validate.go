@@ -0,0 +1,137 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"flag"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// ValidateConfigFile checks the configuration file at path without
+// setting any flags, and returns the problems found: syntax errors,
+// unknown sections and keys, keys of flags that can only be set on the
+// command line, and values that the flags don't accept, such as "x" for
+// an int flag. Unlike Parse, it checks keys in all sections, including
+// sections of other profiles, hosts, and commands, and reports unknown
+// keys regardless of the unknown key policy. It returns nil if the file
+// is valid. A missing file is an error. Use it to implement a command
+// checking configuration before deployment.
+func ValidateConfigFile(path string) []error {
+	return defaultSet.ValidateConfigFile(path)
+}
+
+// ValidateConfigFile checks the configuration file at path without
+// setting any flags. See the package-level ValidateConfigFile.
+func (fs *FlagSet) ValidateConfigFile(path string) []error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []error{err}
+	}
+	entries, err := fs.decodeConfig(path, data)
+	if err != nil {
+		return []error{err}
+	}
+	var errs []error
+	if fs.root().duplicateKeyPolicy == DuplicateKeyError {
+		if _, err := fs.checkDuplicates(entries); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, e := range entries {
+		if err := fs.validateEntry(e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// validateEntry returns an error if the entry can't be applied to the
+// flag set or the set of the command of its section.
+func (fs *FlagSet) validateEntry(e entry) error {
+	if _, _, err := fs.sectionRank(e.section); err != nil {
+		return e.errorf("%s", err)
+	}
+	if isPrefixSection(e.section) {
+		e.name = e.section + "." + e.name
+	}
+	sets := []*FlagSet{fs}
+	if command, ok := strings.CutPrefix(e.section, "command "); ok {
+		c, ok := fs.commands[command]
+		if !ok {
+			return e.errorf("unknown command %s in section [%s]", command, e.section)
+		}
+		sets = []*FlagSet{c}
+	} else {
+		// Keys outside of command sections may set flags of commands.
+		for _, name := range fs.Commands() {
+			sets = append(sets, fs.commands[name])
+		}
+	}
+	for _, s := range sets {
+		if f := s.entryFlag(e); f != nil {
+			return s.validateValue(e, f)
+		}
+	}
+	if e.name == "" || e.name[0] == '-' || strings.ContainsAny(e.name, " \t") {
+		return e.errorf("bad flag syntax: %s", e.name)
+	}
+	return e.errorf("flag provided but not defined: -%s", e.name)
+}
+
+// entryFlag returns the flag to which the entry refers, or nil if there's
+// none.
+func (fs *FlagSet) entryFlag(e entry) *flag.Flag {
+	if f := fs.Lookup(fs.normalizeKey(e.name)); f != nil {
+		return f
+	}
+	if f := fs.lookupNegated(e.name); f != nil && !e.hasValue {
+		return f
+	}
+	if name, ok := fs.migratedName(e.name); ok {
+		return fs.Lookup(name)
+	}
+	return nil
+}
+
+// validateValue returns an error if the entry's value can't be set to
+// the flag. The value is set to a new value of the flag's type, so that
+// the flag itself is unchanged.
+func (fs *FlagSet) validateValue(e entry, f *flag.Flag) (err error) {
+	if fs.cliOnly[f.Name] {
+		return e.errorf("flag -%s can only be set on the command line", e.name)
+	}
+	value := e.value
+	if !e.hasValue {
+		if !isBoolFlag(f) {
+			return e.errorf("flag needs an argument: -%s", e.name)
+		}
+		value = "true"
+	}
+	if !e.literal && hasFlagRefs(value) {
+		// References are resolved when parsing.
+		return nil
+	}
+	t := reflect.TypeOf(f.Value)
+	if t.Kind() != reflect.Pointer {
+		// Can't make a new value without affecting the flag.
+		return nil
+	}
+	defer func() {
+		// Values that need initialization can't be checked.
+		if recover() != nil {
+			err = nil
+		}
+	}()
+	v, ok := reflect.New(t.Elem()).Interface().(flag.Value)
+	if !ok {
+		return nil
+	}
+	if err := v.Set(value); err != nil {
+		return e.invalidValue(fs, f, value, err)
+	}
+	return nil
+}
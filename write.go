@@ -0,0 +1,318 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WriteExampleConfig writes to w a configuration file template listing
+// every defined flag, except hidden and CLI-only ones, with its default
+// value, preceded by its usage text as a comment. Sensitive flags are
+// written commented out, with their default value masked. Grouped flags
+// are written under section comments.
+func WriteExampleConfig(w io.Writer) error {
+	return defaultSet.WriteExampleConfig(w)
+}
+
+// WriteExampleConfig writes to w a configuration file template listing
+// every defined flag, except hidden and CLI-only ones, with its default
+// value. Flags of commands are written in command sections.
+func (fs *FlagSet) WriteExampleConfig(w io.Writer) error {
+	var b strings.Builder
+	fs.writeExample(&b)
+	for _, name := range fs.Commands() {
+		b.WriteString("\n[command " + name + "]\n")
+		fs.commands[name].writeExample(&b)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeExample writes example configuration entries for flags of the set.
+func (fs *FlagSet) writeExample(b *strings.Builder) {
+	first := true
+	for _, g := range fs.flagGroups() {
+		if g.title != "" {
+			if !first {
+				b.WriteString("\n")
+			}
+			b.WriteString("# --- " + g.title + " ---\n")
+			first = true
+		}
+		for _, f := range g.flags {
+			if fs.cliOnly[f.Name] {
+				continue
+			}
+			if !first {
+				b.WriteString("\n")
+			}
+			first = false
+			if _, usage := flag.UnquoteUsage(f); usage != "" {
+				for _, line := range strings.Split(usage, "\n") {
+					b.WriteString(strings.TrimRight("# "+line, " ") + "\n")
+				}
+			}
+			if fs.IsSensitive(f.Name) {
+				b.WriteString("#" + f.Name + "=" + mask + "\n")
+			} else {
+				b.WriteString(f.Name + "=" + quoteValue(f.DefValue) + "\n")
+			}
+		}
+	}
+}
+
+// Save writes the values of flags that differ from their defaults to the
+// configuration file at path. If the file exists, its comments, blank
+// lines, sections and entries for unknown flags are preserved, entries
+// for changed flags, including those in namespace sections such as
+// "[server]", are updated in place, and entries for flags that are now at
+// their default values are removed. Negated entries, such as "no-debug",
+// and obsolete keys migrated with MigrateKey are entries of their flags. Values of other changed flags are
+// appended before the first section. The byte order mark and line
+// endings of the existing file are kept. New files containing sensitive
+// values are created readable only by the owner. The file is replaced
+// atomically, keeping its permissions, so that an interrupted write can't
+// corrupt it, and its previous contents can be kept in a backup file
+// enabled with SetKeepBackup. Only files in the native format can be
+// saved.
+func Save(path string) error {
+	return defaultSet.Save(path)
+}
+
+// Save writes the values of flags that differ from their defaults to the
+// configuration file at path. See the package-level Save.
+func (fs *FlagSet) Save(path string) error {
+	if format := fs.configFormat(path); format != "" {
+		return fmt.Errorf("conflag: can't save configuration in %s format", format)
+	}
+	perm := os.FileMode(0644)
+	lines, bom, eol, err := readLines(path)
+	if err != nil {
+		return err
+	}
+
+	changed := make(map[string]bool)
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Value.String() != f.DefValue && !fs.cliOnly[f.Name] {
+			changed[f.Name] = true
+			if fs.IsSensitive(f.Name) {
+				perm = 0600
+			}
+		}
+	})
+
+	// Entries in profile, host and command sections are kept as is, and
+	// new entries are written before the first section.
+	var result []string
+	section := ""
+	firstSection := -1
+	written := make(map[string]bool)
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		// Find continuation lines of the entry.
+		j := i
+		key := configKey(line)
+		if key != "" {
+			for j+1 < len(lines) && strings.HasSuffix(strings.TrimSpace(lines[j]), `\`) {
+				j++
+			}
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "[") {
+			if s, err := parseSectionHeader(strings.TrimSpace(line)); err == nil {
+				section = s
+			}
+			if firstSection < 0 {
+				firstSection = len(result)
+			}
+		}
+		name := key
+		if key != "" && section != "" {
+			name = ""
+			if isPrefixSection(section) {
+				name = section + "." + key
+			}
+		}
+		var f *flag.Flag
+		if name != "" {
+			if n := fs.keyFlag(name, strings.Contains(line, "=")); n != "" {
+				f = fs.FlagSet.Lookup(n)
+			}
+		}
+		switch {
+		case f == nil:
+			result = append(result, lines[i:j+1]...)
+		case changed[f.Name] && !written[f.Name]:
+			// Keep the key in a section as written, without the prefix of
+			// the section, unless it's a negated or obsolete key.
+			ok := true
+			if section == "" || fs.Lookup(fs.normalizeKey(name)) == nil {
+				key, ok = sectionKey(section, f.Name)
+			}
+			if ok {
+				result = append(result, key+"="+quoteValue(f.Value.String()))
+				written[f.Name] = true
+			}
+		}
+		i = j
+	}
+	var added []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if changed[f.Name] && !written[f.Name] {
+			added = append(added, f.Name+"="+quoteValue(f.Value.String()))
+		}
+	})
+	if firstSection < 0 {
+		firstSection = len(result)
+	}
+	result = append(result[:firstSection], append(added, result[firstSection:]...)...)
+
+	var b strings.Builder
+	b.WriteString(bom)
+	for _, line := range result {
+		b.WriteString(line + eol)
+	}
+	return fs.writeFile(path, []byte(b.String()), perm)
+}
+
+// EnsureUserConfig writes a configuration file template, as written by
+// WriteExampleConfig, to the path of the user configuration file if the
+// file doesn't exist, creating its directory if needed, and reports
+// whether it created the file. Call it on the first run of the program
+// to give users a commented starting point. It does nothing if program
+// name is not set. Only templates in the native format can be written.
+func EnsureUserConfig() (created bool, err error) {
+	return defaultSet.EnsureUserConfig()
+}
+
+// EnsureUserConfig writes a configuration file template to the path of
+// the user configuration file if it doesn't exist.
+// See the package-level EnsureUserConfig.
+func (fs *FlagSet) EnsureUserConfig() (created bool, err error) {
+	path, _, err := fs.findLayerFile(LayerUser)
+	if path == "" || err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		return false, err
+	}
+	if format := fs.configFormat(path); format != "" {
+		return false, fmt.Errorf("conflag: can't write configuration template in %s format", format)
+	}
+	var b strings.Builder
+	if err := fs.WriteExampleConfig(&b); err != nil {
+		return false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, err
+	}
+	if err := fs.writeFile(path, []byte(b.String()), 0644); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SetKeepBackup enables or disables keeping the previous contents of
+// configuration files overwritten by Save in files with the ".bak"
+// extension appended to their names.
+func SetKeepBackup(enable bool) {
+	defaultSet.SetKeepBackup(enable)
+}
+
+// SetKeepBackup enables or disables keeping backups of configuration
+// files overwritten by Save.
+func (fs *FlagSet) SetKeepBackup(enable bool) {
+	fs.root().keepBackup = enable
+}
+
+// writeFile atomically replaces the file at path, or the file to which it
+// links, with the data: it writes a temporary file in the same directory
+// and renames it, so that an interrupted write doesn't corrupt the file.
+// Permissions of an existing file are preserved; new files are created
+// with perm.
+func (fs *FlagSet) writeFile(path string, data []byte, perm os.FileMode) error {
+	if p, err := filepath.EvalSymlinks(path); err == nil {
+		path = p
+	}
+	old, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if fi, err := os.Stat(path); err == nil {
+			perm = fi.Mode().Perm()
+		}
+		if fs.root().keepBackup {
+			if err := os.WriteFile(path+".bak", old, perm); err != nil {
+				return err
+			}
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = f.Chmod(perm)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// readLines returns lines of the existing file at path without line
+// endings, its byte order mark, if any, and the line ending it uses.
+// A missing file has no lines.
+func readLines(path string) (lines []string, bom, eol string, err error) {
+	eol = "\n"
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return nil, "", eol, err
+	}
+	s := string(data)
+	if strings.HasPrefix(s, utf8BOM) {
+		bom, s = utf8BOM, s[len(utf8BOM):]
+	}
+	if strings.Contains(s, "\r\n") {
+		eol = "\r\n"
+	}
+	lines = strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+	return lines, bom, eol, nil
+}
+
+// configKey returns the flag name from the configuration file line,
+// or an empty string if the line is blank or a comment.
+func configKey(line string) string {
+	s := strings.TrimSpace(line)
+	if s == "" || s[0] == '#' {
+		return ""
+	}
+	s, _, _ = strings.Cut(s, "=")
+	name, _ := nativeKey(s)
+	return name
+}
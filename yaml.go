@@ -0,0 +1,32 @@
+// Copyright 2013 Dmitry Chestnykh. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conflag
+
+import "fmt"
+
+// SetYAMLDecoder enables YAML configuration files, with names ending in
+// ".yaml" or ".yml", or selected with SetConfigFormat("yaml"). To keep
+// this package free of dependencies, YAML is decoded by the given function,
+// which is usually Unmarshal from a YAML package:
+//
+//	import "gopkg.in/yaml.v3"
+//
+//	flag.SetYAMLDecoder(yaml.Unmarshal)
+//
+// Keys of nested maps are mapped to dotted flag names, so that "port"
+// under "server" sets the flag "server.port". Each element of a sequence
+// is a separate entry for the same flag, as if the flag was repeated on
+// the command line.
+func SetYAMLDecoder(unmarshal func(data []byte, v any) error) {
+	decode := func(filename string, data []byte) ([]entry, error) {
+		var v any
+		if err := unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("%s: %s", filename, err)
+		}
+		return decodeTree(filename, v)
+	}
+	formats["yaml"] = decode
+	formats["yml"] = decode
+}